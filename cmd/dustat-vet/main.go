@@ -0,0 +1,15 @@
+// Command dustat-vet runs the dustat analyzer as a go vet tool:
+//
+//	go install github.com/tompston/dustat/cmd/dustat-vet@latest
+//	go vet -vettool=$(which dustat-vet) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/tompston/dustat/pkg/analyzer"
+)
+
+func main() {
+	unitchecker.Main(analyzer.Analyzer)
+}
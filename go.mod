@@ -1,3 +1,8 @@
 module github.com/tompston/dustat
 
-go 1.18
\ No newline at end of file
+go 1.18
+
+require (
+	golang.org/x/mod v0.16.0
+	golang.org/x/tools v0.18.0
+)
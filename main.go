@@ -1,26 +1,57 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"sort"
 	"strings"
-	"unicode"
+
+	"github.com/tompston/dustat/pkg/dustat"
+)
+
+// Exit codes, so that CI can tell findings apart from a failure of the tool
+const (
+	exitFindings = 1 // the reported declarations exceed a threshold
+	exitError    = 2 // the analysis could not be run
 )
 
 func main() {
 	if err := runFromCli(); err != nil {
+		var findings *findingsError
+		if errors.As(err, &findings) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitFindings)
+		}
+
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+}
+
+// findingsError is returned when the result exceeds one of the thresholds
+type findingsError struct {
+	msg string
+}
+
+func (e *findingsError) Error() string {
+	return e.msg
+}
+
+// checkThresholds returns a findingsError when there are findings and
+// failOnFindings is set, or when the number of reported declarations or
+// unused lines is above its limit. A negative limit is no limit.
+func checkThresholds(reg *dustat.Registry, failOnFindings bool, maxFindings, maxUnusedLines int) error {
+	switch {
+	case failOnFindings && len(reg.Result) > 0:
+		return &findingsError{fmt.Sprintf("found %d unused declarations", len(reg.Result))}
+	case maxFindings >= 0 && len(reg.Result) > maxFindings:
+		return &findingsError{fmt.Sprintf("found %d unused declarations, more than the allowed %d", len(reg.Result), maxFindings)}
+	case maxUnusedLines >= 0 && reg.TotalUnusedLoc > maxUnusedLines:
+		return &findingsError{fmt.Sprintf("found %d unused lines, more than the allowed %d", reg.TotalUnusedLoc, maxUnusedLines)}
 	}
+	return nil
 }
 
 func runFromCli() error {
@@ -28,469 +59,432 @@ func runFromCli() error {
 	var jsonOutput bool
 	var fix bool
 	var dryRun bool
-	flag.StringVar(&ignoreCsv, "ignore", "", "comma-separated list of exported identifiers to ignore")
-	flag.BoolVar(&jsonOutput, "json", false, "output results in JSON format")
+	var typeCheck bool
+	var interfaceOnly bool
+	var fields bool
+	var tests string
+	var tags, goos, goarch, configList string
+	var generated bool
+	var unusedSuppressions bool
+	var configPath, format string
+	var failOnFindings bool
+	var baselinePath, writeBaseline string
+	var since string
+	var report, usageFrom, consumers string
+	var maxFindings, maxUnusedLines int
+	flag.StringVar(&ignoreCsv, "ignore", "", "comma-separated list of exported identifiers to ignore, as names or [location:]symbol glob patterns (~ for a regexp)")
+	flag.BoolVar(&jsonOutput, "json", false, "output results in JSON format (same as --format=json)")
+	flag.StringVar(&format, "format", "text", "comma-separated output formats, each optionally followed by :file to write it to a file ("+strings.Join(dustat.Formats(), ", ")+")")
+	flag.StringVar(&configPath, "config", "", "path to the configuration file (default: .dustat.yml or .dustat.json in the project directory or a parent)")
+	flag.BoolVar(&failOnFindings, "fail-on-findings", false, "exit with status 1 when any declaration is reported")
+	flag.IntVar(&maxFindings, "max-findings", -1, "exit with status 1 when more than this many declarations are reported, -1 for no limit")
+	flag.IntVar(&maxUnusedLines, "max-unused-lines", -1, "exit with status 1 when the unused declarations span more than this many lines, -1 for no limit")
+	flag.StringVar(&baselinePath, "baseline", "", "only report the findings that are not recorded in this baseline file")
+	flag.StringVar(&writeBaseline, "write-baseline", "", "record the current findings in this baseline file instead of reporting them")
+	flag.StringVar(&report, "report", "", "comma-separated package patterns (./pkg/..., import paths) whose declarations are reported instead of those selected by the arguments, which then only name the project")
+	flag.StringVar(&usageFrom, "usage-from", "", "comma-separated directories or patterns (./cmd/..., ../other-repo) the uses are collected from, along with the reported packages")
+	flag.StringVar(&consumers, "consumers", "", "comma-separated checkouts of the modules using the project, only their uses count and the exports they keep alive are listed")
+	flag.StringVar(&since, "since", "", "only report declarations overlapping the lines changed since this git ref (requires git)")
 	flag.BoolVar(&fix, "fix", false, "automatically rename unused exported symbols to unexported")
 	flag.BoolVar(&dryRun, "dry-run", false, "preview changes without applying them (requires --fix)")
+	flag.BoolVar(&typeCheck, "typecheck", false, "resolve identifiers with full type information (requires the go command)")
+	flag.BoolVar(&interfaceOnly, "interface-only", false, "list methods only used to satisfy an interface instead of treating them as used")
+	flag.BoolVar(&fields, "fields", false, "also report exported struct fields that are never read or written")
+	flag.StringVar(&tests, "tests", string(dustat.TestsCount), "how uses in _test.go files are treated: count, ignore or separate")
+	flag.StringVar(&tags, "tags", "", "comma-separated list of build tags to consider satisfied")
+	flag.StringVar(&goos, "goos", "", "target operating system used to select files (default: the host)")
+	flag.StringVar(&goarch, "goarch", "", "target architecture used to select files (default: the host)")
+	flag.StringVar(&configList, "configs", "", "comma-separated goos/goarch[:tag+tag] configurations, a symbol is unused only if unused in all of them")
+	flag.BoolVar(&generated, "generated", false, "report unused symbols declared in generated files in a separate section")
+	flag.BoolVar(&unusedSuppressions, "unused-suppressions", false, "report //dustat:ignore and //nolint:dustat directives that do not hide anything")
 	flag.Parse()
 
-	if flag.NArg() < 1 {
-		return fmt.Errorf("usage: dustat [--ignore=MyFunc,MyStruct] [--json] [--fix] [--dry-run] <path-to-project>")
-	}
-
-	if dryRun && !fix {
-		return fmt.Errorf("--dry-run requires --fix")
+	if flag.NArg() < 1 && len(splitList(report)) == 0 {
+		return fmt.Errorf("usage: dustat [--config=.dustat.yml] [--ignore=MyFunc,MyStruct] [--json] [--format=text,sarif:dustat.sarif] [--fail-on-findings] [--max-findings=N] [--max-unused-lines=N] [--baseline=file] [--write-baseline=file] [--since=main] [--fix] [--dry-run] [--typecheck] [--interface-only] [--fields] [--tests=count|ignore|separate] [--tags=a,b] [--goos=linux] [--goarch=amd64] [--configs=linux/amd64,windows/amd64] [--generated] [--unused-suppressions] [--report=./pkg/...] [--usage-from=./cmd/...,../other-repo] [--consumers=../service-a,../service-b] <path-to-project | packages...>")
 	}
 
-	projectPath, err := getProjectPath(flag.Arg(0))
+	projectPath, packages, err := projectSelection(flag.Args(), report)
 	if err != nil {
-		return fmt.Errorf("error getting project path: %v", err)
+		return err
 	}
 
-	reg, err := NewRegistry(projectPath)
+	var config *dustat.Config
+	if configPath != "" {
+		config, err = dustat.LoadConfig(configPath)
+	} else {
+		config, err = dustat.FindConfig(projectPath)
+	}
 	if err != nil {
-		return fmt.Errorf("error creating registry: %v", err)
+		return err
+	}
+	if config == nil {
+		config = &dustat.Config{}
 	}
 
-	ignore := make(map[string]struct{})
-	if ignoreCsv != "" {
-		for _, name := range strings.Split(ignoreCsv, ",") {
-			ignore[strings.TrimSpace(name)] = struct{}{}
-		}
+	// the flags given on the command line override the configuration file
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
 
-		reg.WithIgnoreList(ignore)
+	// the patterns of the configuration file are relative to it
+	var usagePatterns []string
+	if len(packages) == 0 {
+		packages = configPatterns(config, config.Report)
 	}
-
-	if err := reg.Run(!fix, jsonOutput); err != nil {
-		return err
+	if set["usage-from"] {
+		if _, usagePatterns, err = resolvePatterns(splitList(usageFrom)); err != nil {
+			return fmt.Errorf("invalid --usage-from value: %v", err)
+		}
+	} else {
+		usagePatterns = configPatterns(config, config.UsageFrom)
 	}
-
-	if fix {
-		return reg.Fix(dryRun)
+	for _, pattern := range usagePatterns {
+		if !filepath.IsAbs(pattern) {
+			return fmt.Errorf("invalid --usage-from value: %s is not a directory", pattern)
+		}
 	}
 
-	return nil
-}
-
-type Decl struct {
-	Name      string
-	Pos       token.Position
-	End       token.Position
-	LineCount int
-}
-
-type Registry struct {
-	Path           string              // Path is the root path of the project being analyzed
-	Ignore         map[string]struct{} // Identifiers that should be ignored in the analysis
-	Declarations   map[string]Decl     // Declarations holds all exported identifiers found in the project
-	UsageCount     map[string]int      // UsageCount tracks how many times each identifier is used
-	Result         []Decl              // Result holds the final unused declarations
-	TotalUnusedLoc int                 // TotalUnusedLoc counts the total number of unused lines across all unused declarations
-}
-
-func NewRegistry(path string) (*Registry, error) {
-	if path == "" {
-		return nil, fmt.Errorf("path cannot be empty")
+	consumerDirs := config.Paths(config.Consumers)
+	if set["consumers"] {
+		consumerDirs = nil
+		for _, dir := range splitList(consumers) {
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("invalid --consumers value: %v", err)
+			}
+			consumerDirs = append(consumerDirs, abs)
+		}
 	}
-
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, fmt.Errorf("path does not exist: %s", path)
+	for _, dir := range consumerDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("invalid --consumers value: %s is not a directory", dir)
+		}
 	}
 
-	return &Registry{
-		Declarations: make(map[string]Decl),
-		UsageCount:   make(map[string]int),
-		Ignore:       make(map[string]struct{}),
-		Result:       []Decl{},
-		Path:         path,
-	}, nil
-}
-
-func (reg *Registry) WithIgnoreList(ignore map[string]struct{}) *Registry {
-	if ignore == nil {
-		ignore = make(map[string]struct{})
+	ignoreList := ignoreEntries(ignoreCsv, set["ignore"], config)
+	if !set["tests"] && config.Tests != "" {
+		tests = config.Tests
 	}
-
-	reg.Ignore = ignore
-	return reg
-}
-
-func (reg *Registry) Run(printResult bool, jsonOutput bool) error {
-	if err := reg.ParseFiles(); err != nil {
-		return fmt.Errorf("error parsing project: %v", err)
+	formatFromConfig := !set["format"] && config.Format != ""
+	if formatFromConfig {
+		format = config.Format
 	}
-
-	if err := reg.AccumulateResult(); err != nil {
-		return fmt.Errorf("error accumulating results: %v", err)
+	if !set["baseline"] && config.Baseline != "" {
+		baselinePath = config.Paths([]string{config.Baseline})[0]
 	}
-
-	if printResult {
-		reg.Report(jsonOutput)
+	if !set["fail-on-findings"] && config.FailOnFindings != nil {
+		failOnFindings = *config.FailOnFindings
+	}
+	if !set["max-findings"] && config.MaxFindings != nil {
+		maxFindings = *config.MaxFindings
+	}
+	if !set["max-unused-lines"] && config.MaxUnusedLines != nil {
+		maxUnusedLines = *config.MaxUnusedLines
+	}
+	if jsonOutput {
+		format, formatFromConfig = "json", false
 	}
 
-	return nil
-}
-
-func (reg *Registry) ParseFiles() error {
+	specs, err := parseOutputSpecs(format)
+	if err != nil {
+		return fmt.Errorf("invalid --format value: %v", err)
+	}
+	if formatFromConfig {
+		for i := range specs {
+			if specs[i].path != "" {
+				specs[i].path = config.Paths([]string{specs[i].path})[0]
+			}
+		}
+	}
 
-	fset := token.NewFileSet()
-	projectPath := reg.Path
+	testMode, err := dustat.ParseTestMode(tests)
+	if err != nil {
+		return err
+	}
 
-	// First pass: collect declarations and usage from non-test files
-	if err := filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
+	var configs []dustat.BuildConfig
+	switch {
+	case configList != "" && (goos != "" || goarch != ""):
+		return fmt.Errorf("--configs cannot be combined with --goos or --goarch")
+	case configList != "":
+		if configs, err = dustat.ParseBuildConfigs(configList, splitList(tags)); err != nil {
 			return err
 		}
+	case tags != "" || goos != "" || goarch != "":
+		configs = []dustat.BuildConfig{{GOOS: goos, GOARCH: goarch, Tags: splitList(tags)}}
+	}
 
-		if info.IsDir() && (info.Name() == "vendor" || info.Name() == "testdata" || strings.HasPrefix(info.Name(), ".")) {
-			return filepath.SkipDir
-		}
+	if dryRun && !fix {
+		return fmt.Errorf("--dry-run requires --fix")
+	}
 
-		if info.IsDir() || !strings.HasSuffix(info.Name(), ".go") {
-			return nil // skip test files for this pass
-		}
+	reg, err := dustat.NewRegistry(projectPath)
+	if err != nil {
+		return fmt.Errorf("error creating registry: %v", err)
+	}
 
-		file, err := parser.ParseFile(fset, path, nil, parser.AllErrors|parser.ParseComments)
-		if err != nil {
-			return fmt.Errorf("error parsing file %s: %v", path, err)
+	if len(ignoreList) > 0 {
+		ignore := make(map[string]struct{})
+		for _, name := range ignoreList {
+			ignore[strings.TrimSpace(name)] = struct{}{}
 		}
 
-		// Collect exported declarations
-		for _, decl := range file.Decls {
-			switch d := decl.(type) {
-			case *ast.FuncDecl:
-				if d.Name.IsExported() {
-					reg.Declarations[d.Name.Name] = makeDecl(d.Name.Name, d.Name.Pos(), d.End(), fset)
-				}
-
-			case *ast.GenDecl:
-				for _, spec := range d.Specs {
-					switch s := spec.(type) {
-					case *ast.TypeSpec:
-						if s.Name.IsExported() {
-
-							reg.Declarations[s.Name.Name] = makeDecl(s.Name.Name, s.Name.Pos(), s.End(), fset)
-						}
-
-					case *ast.ValueSpec:
-						for _, name := range s.Names {
-							if name.IsExported() {
-								reg.Declarations[name.Name] = makeDecl(name.Name, name.Pos(), s.End(), fset)
-							}
-						}
-					}
-				}
-			}
+		reg.WithIgnoreList(ignore)
+	}
+
+	reg.WithIgnorePackages(config.IgnorePackages...)
+	reg.WithIgnorePaths(config.Paths(config.IgnorePaths)...)
+	reg.WithExclude(config.Paths(config.Exclude)...)
+	reg.WithPackages(packages...)
+	reg.WithUsageFrom(usagePatterns...)
+	reg.WithConsumers(consumerDirs...)
+	reg.WithTypeCheck(typeCheck)
+	reg.WithInterfaceOnly(interfaceOnly)
+	reg.WithFields(fields)
+	reg.WithTests(testMode)
+	reg.WithBuildConfigs(configs...)
+	reg.WithGenerated(generated)
+	reg.WithUnusedSuppressions(unusedSuppressions)
+	reg.WithSince(since)
+
+	// a new baseline records every finding, including those of the old one
+	if writeBaseline != "" {
+		if err := runRegistry(reg); err != nil {
+			return err
 		}
 
-		// Collect usage
-		ast.Inspect(file, func(n ast.Node) bool {
-			if ident, ok := n.(*ast.Ident); ok {
-				reg.UsageCount[ident.Name]++
-			}
-			return true
-		})
+		if err := dustat.NewBaseline(reg.Result).Write(writeBaseline); err != nil {
+			return err
+		}
 
+		fmt.Printf("Wrote %d findings to %s\n", len(reg.Result), writeBaseline)
 		return nil
-	}); err != nil {
-		return fmt.Errorf("error walking project: %v", err)
 	}
 
-	// Second pass: collect usage from _test.go files
-	if err := filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
+	if baselinePath != "" {
+		baseline, err := dustat.LoadBaseline(baselinePath)
 		if err != nil {
 			return err
 		}
+		reg.WithBaseline(baseline)
+	}
 
-		if info.IsDir() || !strings.HasSuffix(info.Name(), "_test.go") {
-			return nil
-		}
-
-		file, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-		if err != nil {
-			return fmt.Errorf("error parsing test file %s: %v", path, err)
+	if fix {
+		if err := runRegistry(reg); err != nil {
+			return err
 		}
-
-		ast.Inspect(file, func(n ast.Node) bool {
-			if ident, ok := n.(*ast.Ident); ok {
-				reg.UsageCount[ident.Name]++
-			}
-			return true
-		})
-
-		return nil
-	}); err != nil {
-		return fmt.Errorf("error walking test files: %v", err)
+		return reg.Fix(dryRun, os.Stdout)
 	}
 
-	return nil
-}
-
-func (reg *Registry) AccumulateResult() error {
-	for name, decl := range reg.Declarations {
-		if _, ignore := reg.Ignore[name]; ignore {
+	var outputs []dustat.Output
+	for _, spec := range specs {
+		reporter, _ := dustat.LookupReporter(spec.format)
+		if spec.path == "" {
+			outputs = append(outputs, dustat.Output{Reporter: reporter, Writer: os.Stdout})
 			continue
 		}
 
-		if reg.UsageCount[name] <= 1 {
-			reg.Result = append(reg.Result, decl)
-			reg.TotalUnusedLoc += decl.LineCount
+		file, err := os.Create(spec.path)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %v", err)
 		}
+		defer file.Close()
+		outputs = append(outputs, dustat.Output{Reporter: reporter, Writer: file})
 	}
 
-	return nil
-}
+	if err := runRegistry(reg, outputs...); err != nil {
+		return err
+	}
 
-type Issue struct {
-	Symbol string `json:"symbol"`
-	Line   int    `json:"line"`
+	return checkThresholds(reg, failOnFindings, maxFindings, maxUnusedLines)
 }
 
-type FileIssues struct {
-	File   string  `json:"file"`
-	Issues []Issue `json:"issues"`
+// runRegistry runs the analysis, and prints the type errors it tolerated on
+// stderr
+func runRegistry(reg *dustat.Registry, outputs ...dustat.Output) error {
+	err := reg.Run(outputs...)
+	for _, typeErr := range reg.TypeErrors {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", typeErr)
+	}
+	return err
 }
 
-func (reg *Registry) Report(jsonOutput bool) {
-	if jsonOutput {
-		reg.ReportJSON()
-		return
+// projectArgs returns the directory to analyze and the package patterns
+// selecting the reported declarations. Every argument is a pattern of the go
+// command (./..., ./internal/..., an import path), or a directory standing for
+// everything under it, and the uses are collected from the whole module or
+// workspace containing them, see dustat.ProjectRoot. No pattern is returned
+// when a single directory is that project itself.
+func projectArgs(args []string) (string, []string, error) {
+	roots, patterns, err := resolvePatterns(args)
+	if err != nil {
+		return "", nil, err
 	}
 
-	if len(reg.Result) > 0 {
-
-		// sort ascending by the number of lines in the declaration
-		sort.Slice(reg.Result, func(i, j int) bool {
-			return reg.Result[i].LineCount < reg.Result[j].LineCount
-		})
-
-		fmt.Printf("Unused Exported Symbols (ignoring test-only usage):\n")
-		fmt.Println("========================================================")
-
-		for _, decl := range reg.Result {
-			fmt.Printf("%-5v %s (%v)\n", decl.LineCount, decl.Name, decl.Pos.String())
-		}
-
-		fmt.Println("========================================================")
-		fmt.Printf("Total Unused Lines: %d, Declarations: %v\n", reg.TotalUnusedLoc, len(reg.Result))
-
-	} else {
-		fmt.Println("No unused exported identifiers found!")
+	// a single directory that is the project itself selects all of it
+	root := commonDir(roots)
+	if len(patterns) == 1 && patterns[0] == filepath.Join(root, "...") && !strings.Contains(args[0], "...") {
+		return root, nil, nil
 	}
+	return root, patterns, nil
 }
 
-func (reg *Registry) ReportJSON() {
-	// Group issues by file
-	fileMap := make(map[string][]Issue)
-	for _, decl := range reg.Result {
-		filePath := decl.Pos.Filename
-		fileMap[filePath] = append(fileMap[filePath], Issue{
-			Symbol: decl.Name,
-			Line:   decl.Pos.Line,
-		})
-	}
-
-	// Convert map to slice and sort by file path
-	results := []FileIssues{}
-	for file, issues := range fileMap {
-		// Sort issues by line number within each file
-		sort.Slice(issues, func(i, j int) bool {
-			return issues[i].Line < issues[j].Line
-		})
-		results = append(results, FileIssues{
-			File:   file,
-			Issues: issues,
-		})
-	}
-
-	// Sort by file path
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].File < results[j].File
-	})
-
-	// Output JSON
-	output, err := json.MarshalIndent(results, "", "  ")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error marshaling JSON: %v\n", err)
-		return
+// projectSelection returns the directory to analyze and the package patterns
+// selecting the reported declarations, see projectArgs. The --report patterns
+// replace the selection of the arguments, which then only name the project.
+// Without arguments, the project is the one of the --report patterns.
+func projectSelection(args []string, report string) (string, []string, error) {
+	reported := splitList(report)
+	if len(args) == 0 {
+		args = reported
+	}
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("no project path or --report pattern given")
 	}
-	fmt.Println(string(output))
-}
 
-func makeDecl(name string, start, end token.Pos, fset *token.FileSet) Decl {
-	pos := fset.Position(start)
-	endPos := fset.Position(end)
-	return Decl{
-		LineCount: endPos.Line - pos.Line + 1,
-		End:       endPos,
-		Pos:       pos,
-		Name:      name,
+	projectPath, packages, err := projectArgs(args)
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting project path: %v", err)
 	}
-}
 
-// Fix renames all unused exported symbols to unexported using gopls
-func (reg *Registry) Fix(dryRun bool) error {
-	// Check if gopls is installed
-	if _, err := exec.LookPath("gopls"); err != nil {
-		return fmt.Errorf("gopls not found. Install with: go install golang.org/x/tools/gopls@latest")
+	if len(reported) > 0 {
+		if _, packages, err = resolvePatterns(reported); err != nil {
+			return "", nil, fmt.Errorf("invalid --report value: %v", err)
+		}
 	}
+	return projectPath, packages, nil
+}
 
-	if len(reg.Result) == 0 {
-		fmt.Println("No unused exported symbols to fix!")
-		return nil
+// resolvePatterns makes the directory patterns absolute, a directory standing
+// for everything under it, and returns the project root of each pattern
+func resolvePatterns(args []string) ([]string, []string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to determine current working directory: %v", err)
 	}
 
-	// Sort by file and line to process in order
-	sort.Slice(reg.Result, func(i, j int) bool {
-		if reg.Result[i].Pos.Filename != reg.Result[j].Pos.Filename {
-			return reg.Result[i].Pos.Filename < reg.Result[j].Pos.Filename
+	var roots, patterns []string
+	for _, arg := range args {
+		// import paths are resolved in the module of the working directory,
+		// an existing directory is taken as one even without a leading "./"
+		if info, err := os.Stat(arg); err == nil && info.IsDir() && !dustat.IsPathPattern(arg) {
+			arg = "." + string(filepath.Separator) + arg
+		}
+		if !dustat.IsPathPattern(arg) {
+			roots = append(roots, dustat.ProjectRoot(cwd))
+			patterns = append(patterns, arg)
+			continue
 		}
-		return reg.Result[i].Pos.Line < reg.Result[j].Pos.Line
-	})
-
-	successful := 0
-	skipped := 0
-	failed := 0
 
-	for _, decl := range reg.Result {
-		newName := toUnexported(decl.Name)
+		pattern, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to resolve absolute path: %v", err)
+		}
 
-		// Skip if name doesn't change (shouldn't happen with exported symbols)
-		if newName == decl.Name {
-			if dryRun {
-				fmt.Printf("⊘ Skip: %s (already unexported) at %s\n", decl.Name, decl.Pos.String())
+		// the directory the pattern starts from, before any "..."
+		dir := pattern
+		if i := strings.Index(pattern, "..."); i >= 0 {
+			dir = pattern[:i]
+			if !strings.HasSuffix(dir, string(filepath.Separator)) {
+				dir = filepath.Dir(dir)
 			}
-			skipped++
-			continue
+			dir = filepath.Clean(dir)
+		} else {
+			pattern = filepath.Join(pattern, "...")
 		}
 
-		if dryRun {
-			fmt.Printf("→ Would rename: %s -> %s at %s\n", decl.Name, newName, decl.Pos.String())
-			successful++
-			continue
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, nil, fmt.Errorf("directory does not exist: %s", dir)
 		}
 
-		// Use gopls to rename
-		position := fmt.Sprintf("%s:%d:%d", decl.Pos.Filename, decl.Pos.Line, decl.Pos.Column)
-
-		cmd := exec.Command("gopls", "rename", "-w", position, newName)
+		roots = append(roots, dustat.ProjectRoot(dir))
+		patterns = append(patterns, pattern)
+	}
 
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
+	return roots, patterns, nil
+}
 
-		if err := cmd.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "✗ Failed to rename %s: %v\n  %s\n", decl.Name, err, stderr.String())
-			failed++
-			continue
+// configPatterns returns the package patterns of the configuration file like
+// resolvePatterns does, the directories being relative to the file
+func configPatterns(config *dustat.Config, patterns []string) []string {
+	var resolved []string
+	for _, pattern := range patterns {
+		if dustat.IsPathPattern(pattern) {
+			pattern = config.Paths([]string{pattern})[0]
+			if !strings.Contains(pattern, "...") {
+				pattern = filepath.Join(pattern, "...")
+			}
 		}
+		resolved = append(resolved, pattern)
+	}
+	return resolved
+}
 
-		fmt.Printf("✓ Renamed: %s -> %s\n", decl.Name, newName)
-		successful++
+// ignoreEntries returns the ignore patterns of the --ignore flag, or those of
+// the config when the flag is not set. The entries of the config are kept
+// whole, since a pattern may contain commas: ~^A{2,3}$
+func ignoreEntries(flagValue string, flagSet bool, config *dustat.Config) []string {
+	if !flagSet && len(config.Ignore) > 0 {
+		return config.Ignore
 	}
+	return splitList(flagValue)
+}
 
-	fmt.Println()
-	if dryRun {
-		fmt.Printf("Dry-run summary: %d would be renamed, %d skipped\n", successful, skipped)
-	} else {
-		fmt.Printf("Summary: %d renamed, %d skipped, %d failed\n", successful, skipped, failed)
-		if failed > 0 {
-			return fmt.Errorf("some renames failed")
-		}
+// commonDir returns the deepest directory containing all of the absolute dirs
+func commonDir(dirs []string) string {
+	if len(dirs) == 0 {
+		return ""
 	}
 
-	return nil
-}
+	common := filepath.Clean(dirs[0])
+	for _, dir := range dirs[1:] {
+		for {
+			rel, err := filepath.Rel(common, dir)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				break
+			}
 
-// toUnexported converts an exported identifier to unexported following Go naming conventions.
-// It properly handles acronyms and initialisms:
-// - HTTPServer -> httpServer (not hTTPServer)
-// - XMLParser -> xmlParser (not xMLParser)
-// - APIHandler -> apiHandler (not aPIHandler)
-// - ID -> id (not iD)
-// - URLPath -> urlPath
-// - HTTPs -> https (not httPs)
-func toUnexported(name string) string {
-	if name == "" {
-		return name
-	}
-
-	runes := []rune(name)
-	if len(runes) == 1 {
-		return string(unicode.ToLower(runes[0]))
-	}
-
-	// Find the end of the leading uppercase sequence
-	// This tells us where the first non-uppercase character is
-	firstLower := -1
-	for i := 0; i < len(runes); i++ {
-		if !unicode.IsUpper(runes[i]) {
-			firstLower = i
-			break
+			parent := filepath.Dir(common)
+			if parent == common {
+				break
+			}
+			common = parent
 		}
 	}
+	return common
+}
 
-	// If the whole name is uppercase, lowercase it all
-	// Example: "HTTP" -> "http", "ID" -> "id"
-	if firstLower == -1 {
-		for i := range runes {
-			runes[i] = unicode.ToLower(runes[i])
+// outputSpec is an entry of --format, a format optionally followed by the file
+// written instead of stdout: text,sarif:dustat.sarif
+type outputSpec struct {
+	format string
+	path   string
+}
+
+func parseOutputSpecs(list string) ([]outputSpec, error) {
+	var specs []outputSpec
+	for _, entry := range splitList(list) {
+		format, path, _ := strings.Cut(entry, ":")
+		if _, err := dustat.LookupReporter(format); err != nil {
+			return nil, err
 		}
-		return string(runes)
-	}
-
-	// Now we have some uppercase letters followed by at least one non-uppercase
-	// Determine how many leading characters to lowercase
-	toLowerCount := firstLower
-
-	// If we have 2+ uppercase letters followed by an uppercase letter (new word),
-	// the last uppercase before the new word should not be lowercased
-	// Example: "HTTPServer" -> firstLower=4 (at 'e'), runes[4-1]='S' is uppercase
-	//          We want "http" + "Server", so lowercase first 4 chars (indices 0-3)
-	// Example: "HTTPs" -> firstLower=4 (at 's'), runes[4-1]='P' is uppercase
-	//          We want "https", so lowercase first 4 chars (indices 0-3)
-	// Example: "MyFunc" -> firstLower=1 (at 'y'), runes[0]='M'
-	//          We want "myFunc", so lowercase first 1 char
-
-	if firstLower > 1 && unicode.IsUpper(runes[firstLower-1]) {
-		// We have multiple uppercase letters before a non-uppercase
-		// Check if the non-uppercase character is followed by more content
-		// and if runes[firstLower-1] starts a new capitalized word
-
-		// Pattern: "HTTPServer" where 'S' at firstLower-1 is start of "Server"
-		// We want to keep 'S' capitalized, so only lowercase up to 'P'
-		if firstLower < len(runes)-1 {
-			// There are more characters after firstLower
-			// The uppercase at firstLower-1 likely starts a new word
-			toLowerCount = firstLower - 1
+		if path == "-" || path == "stdout" {
+			path = ""
 		}
-		// else: Pattern like "HTTPs" - lowercase all the uppercase part
+		specs = append(specs, outputSpec{format, path})
 	}
 
-	// Lowercase the determined portion
-	for i := 0; i < toLowerCount; i++ {
-		runes[i] = unicode.ToLower(runes[i])
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no output format in %q", list)
 	}
-
-	return string(runes)
+	return specs, nil
 }
 
-func getProjectPath(cliPath string) (string, error) {
-	if cliPath == "" {
-		return "", fmt.Errorf("no project path provided")
-	}
-
-	if cliPath == "." {
-		cwd, err := os.Getwd()
-		if err != nil {
-			return "", fmt.Errorf("unable to determine current working directory: %v", err)
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-		return cwd, nil
 	}
-
-	absPath, err := filepath.Abs(cliPath)
-	if err != nil {
-		return "", fmt.Errorf("unable to resolve absolute path: %v", err)
-	}
-
-	return absPath, nil
+	return items
 }
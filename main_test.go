@@ -1,471 +1,260 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
-	"unicode"
-)
-
-func TestFlow(t *testing.T) {
-	const testProjectPath = "./test"
 
-	t.Run("all-unused-found", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
-
-		if err := reg.Run(false, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
+	"github.com/tompston/dustat/pkg/dustat"
+)
 
-		fmt.Printf("reg.Result: %v\n", reg.Result)
+func TestCheckThresholds(t *testing.T) {
+	reg, err := dustat.NewRegistry("./pkg/dustat/testdata/project")
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
 
-		if len(reg.Result) == 0 {
-			t.Fatal("expected unused declarations, but found none")
-		}
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
 
-		if err := resultIncludesName(reg.Result, "UnusedStruct"); err != nil {
-			t.Errorf("expected unused declaration 'UnusedStruct' not found: %v", err)
-		}
-	})
+	findings, lines := len(reg.Result), reg.TotalUnusedLoc
 
-	t.Run("igore-list-works", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
+	tests := []struct {
+		name           string
+		failOnFindings bool
+		maxFindings    int
+		maxUnusedLines int
+		fail           bool
+	}{
+		{"no-thresholds", false, -1, -1, false},
+		{"fail-on-findings", true, -1, -1, true},
+		{"findings-at-limit", false, findings, -1, false},
+		{"findings-over-limit", false, findings - 1, -1, true},
+		{"lines-at-limit", false, -1, lines, false},
+		{"lines-over-limit", false, -1, lines - 1, true},
+	}
 
-		ignoreList := map[string]struct{}{
-			"UnusedButIgnoredStruct": {},
-		}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkThresholds(reg, tt.failOnFindings, tt.maxFindings, tt.maxUnusedLines)
+			if !tt.fail {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
 
-		reg.WithIgnoreList(ignoreList)
+			var findingsErr *findingsError
+			if !errors.As(err, &findingsErr) {
+				t.Errorf("expected a findings error, got %v", err)
+			}
+		})
+	}
+}
 
-		if err := reg.Run(true, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
+func TestParseOutputSpecs(t *testing.T) {
+	specs, err := parseOutputSpecs("text, sarif:out/dustat.sarif,json:-")
+	if err != nil {
+		t.Fatalf("failed to parse output specs: %v", err)
+	}
 
-		if err := resultIncludesName(reg.Result, "UnusedButIgnoredStruct"); err == nil {
-			t.Fatal("expected ignored declaration 'UnusedButIgnoredStruct' to be excluded, but it was found")
-		}
+	want := []outputSpec{{"text", ""}, {"sarif", "out/dustat.sarif"}, {"json", ""}}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("expected %+v, got %+v", want, specs)
+	}
 
-		if len(reg.Result) != 2 {
-			t.Fatalf("expected 2 unused declarations, but found %d", len(reg.Result))
+	for _, list := range []string{"yaml", "", "text,xml:out.xml"} {
+		if _, err := parseOutputSpecs(list); err == nil {
+			t.Errorf("expected an error for %q", list)
 		}
-	})
+	}
 }
 
-func resultIncludesName(result []Decl, name string) error {
-	for _, decl := range result {
-		if decl.Name == name {
-			return nil
-		}
+func TestProjectArgs(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
 	}
+	testdata := filepath.Join(cwd, "pkg", "dustat", "testdata")
+	multipkg := filepath.Join(testdata, "multipkg")
 
-	return fmt.Errorf("expected name %v not found in result", name)
-}
-
-func TestToUnexported(t *testing.T) {
 	tests := []struct {
 		name     string
-		input    string
-		expected string
+		args     []string
+		root     string
+		patterns []string
 	}{
-		// Basic cases
-		{"simple", "MyFunc", "myFunc"},
-		{"single char", "A", "a"},
-		{"empty", "", ""},
-		{"already lowercase", "myFunc", "myFunc"},
-
-		// Common acronyms with words
-		{"HTTP with word", "HTTPServer", "httpServer"},
-		{"API with word", "APIHandler", "apiHandler"},
-		{"XML with word", "XMLParser", "xmlParser"},
-		{"URL with word", "URLPath", "urlPath"},
-		{"JSON with word", "JSONEncoder", "jsonEncoder"},
-		{"SQL with word", "SQLDatabase", "sqlDatabase"},
-		{"TCP with word", "TCPConnection", "tcpConnection"},
-		{"UDP with word", "UDPSocket", "udpSocket"},
-		{"TLS with word", "TLSConfig", "tlsConfig"},
-		{"DNS with word", "DNSResolver", "dnsResolver"},
-
-		// ID cases (special case mentioned in requirements)
-		{"ID alone", "ID", "id"},
-		{"ID with prefix", "UserID", "userID"},
-		{"ID with suffix", "IDGenerator", "idGenerator"},
-		{"AppID", "AppID", "appID"},
-		{"RequestID", "RequestID", "requestID"},
-
-		// Multiple acronyms
-		{"HTTP + API", "HTTPAPI", "httpapi"},
-		{"XML + HTTP", "XMLHTTP", "xmlhttp"},
-		{"HTTP + URL", "HTTPURL", "httpurl"},
-		{"API + URL", "APIURL", "apiurl"},
-
-		// Multiple acronyms with word
-		{"XML + HTTP + Request", "XMLHTTPRequest", "xmlhttpRequest"},
-		{"API + URL + Path", "APIURLPath", "apiurlPath"},
-
-		// All caps (acronyms only)
-		{"HTTP only", "HTTP", "http"},
-		{"API only", "API", "api"},
-		{"XML only", "XML", "xml"},
-		{"URL only", "URL", "url"},
-		{"JSON only", "JSON", "json"},
-
-		// Edge cases
-		{"two letters", "IO", "io"},
-		{"acronym + single letter", "HTTPs", "https"},
-		{"single uppercase + word", "AStruct", "aStruct"},
-
-		// Real-world examples
-		{"HTTPClient", "HTTPClient", "httpClient"},
-		{"HTTPRequest", "HTTPRequest", "httpRequest"},
-		{"HTTPResponse", "HTTPResponse", "httpResponse"},
-		{"XMLDecoder", "XMLDecoder", "xmlDecoder"},
-		{"JSONMarshaler", "JSONMarshaler", "jsonMarshaler"},
-		{"URLEncoder", "URLEncoder", "urlEncoder"},
-		{"APIClient", "APIClient", "apiClient"},
-		{"APIKey", "APIKey", "apiKey"},
-		{"DBConnection", "DBConnection", "dbConnection"},
-		{"OSVersion", "OSVersion", "osVersion"},
-
-		// Should NOT use mixed case
-		{"avoid Url", "Url", "url"},    // Should be URL or url, not Url
-		{"avoid Http", "Http", "http"}, // Should be HTTP or http, not Http
-		{"avoid Id", "Id", "id"},       // Should be ID or id, not Id
-		{"avoid Api", "Api", "api"},    // Should be API or api, not Api
+		{"project-directory", []string{"./pkg/dustat/testdata/multipkg"}, multipkg, nil},
+		{"directory-without-dot", []string{"pkg/dustat/testdata/multipkg/a"}, multipkg, []string{filepath.Join(multipkg, "a", "...")}},
+		{"single-directory", []string{"./pkg/dustat/testdata/multipkg/a"}, multipkg, []string{filepath.Join(multipkg, "a", "...")}},
+		{"wildcard", []string{"./pkg/dustat/testdata/multipkg/a/..."}, multipkg, []string{filepath.Join(multipkg, "a", "...")}},
+		{"import-path", []string{"github.com/tompston/dustat/pkg/..."}, cwd, []string{"github.com/tompston/dustat/pkg/..."}},
+		{
+			"several-directories",
+			[]string{"./pkg/dustat/testdata/multipkg/a", "./pkg/dustat/testdata/multipkg/b"},
+			multipkg,
+			[]string{filepath.Join(multipkg, "a", "..."), filepath.Join(multipkg, "b", "...")},
+		},
+		{
+			"several-modules",
+			[]string{"./pkg/dustat/testdata/multipkg/...", "./pkg/dustat/testdata/typed/..."},
+			testdata,
+			[]string{filepath.Join(multipkg, "..."), filepath.Join(testdata, "typed", "...")},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := toUnexported(tt.input)
-			if result != tt.expected {
-				t.Errorf("toUnexported(%q) = %q; want %q", tt.input, result, tt.expected)
+			root, patterns, err := projectArgs(tt.args)
+			if err != nil {
+				t.Fatalf("failed to resolve arguments: %v", err)
 			}
-		})
-	}
-}
 
-func TestToUnexportedProperties(t *testing.T) {
-	t.Run("result should start with lowercase", func(t *testing.T) {
-		inputs := []string{"MyFunc", "HTTPServer", "ID", "APIKey", "XMLParser"}
-		for _, input := range inputs {
-			if input == "" {
-				continue
+			if root != tt.root {
+				t.Errorf("expected root %s, got %s", tt.root, root)
 			}
-			result := toUnexported(input)
-			if result != "" && !unicode.IsLower(rune(result[0])) {
-				t.Errorf("toUnexported(%q) = %q; first character should be lowercase", input, result)
-			}
-		}
-	})
-
-	t.Run("should not create mixed-case acronyms", func(t *testing.T) {
-		// These are invalid patterns we want to avoid
-		badPatterns := []struct {
-			input    string
-			badStart string
-		}{
-			{"HTTPServer", "hTTP"}, // Should be "http", not "hTTP"
-			{"APIHandler", "aPI"},  // Should be "api", not "aPI"
-			{"XMLParser", "xML"},   // Should be "xml", not "xML"
-			{"ID", "iD"},           // Should be "id", not "iD"
-		}
-
-		for _, bp := range badPatterns {
-			result := toUnexported(bp.input)
-			if len(result) >= len(bp.badStart) && result[:len(bp.badStart)] == bp.badStart {
-				t.Errorf("toUnexported(%q) = %q; should not start with %q (mixed-case acronym)",
-					bp.input, result, bp.badStart)
+			if !reflect.DeepEqual(patterns, tt.patterns) {
+				t.Errorf("expected patterns %v, got %v", tt.patterns, patterns)
 			}
-		}
-	})
-}
-
-// captureJSONOutput captures stdout and returns the JSON output as a string
-func captureJSONOutput(t *testing.T, fn func()) string {
-	t.Helper()
-
-	oldStdout := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	fn()
-
-	if err := w.Close(); err != nil {
-		t.Fatalf("failed to close pipe writer: %v", err)
+		})
 	}
-	os.Stdout = oldStdout
 
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, r); err != nil {
-		t.Fatalf("failed to copy output: %v", err)
+	if _, _, err := projectArgs([]string{"./missing/..."}); err == nil {
+		t.Error("expected an error for a missing directory")
 	}
-
-	return buf.String()
 }
 
-func TestJSONOutput(t *testing.T) {
-	const testProjectPath = "./test"
-
-	t.Run("json-output-is-valid", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
-
-		if err := reg.Run(false, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
-
-		output := captureJSONOutput(t, func() {
-			reg.ReportJSON()
-		})
-
-		// Verify output is valid JSON
-		var result []FileIssues
-		if err := json.Unmarshal([]byte(output), &result); err != nil {
-			t.Fatalf("failed to parse JSON output: %v\nOutput: %s", err, output)
-		}
-
-		if len(result) == 0 {
-			t.Fatal("expected at least one file with issues in JSON output")
-		}
-	})
-
-	t.Run("json-output-contains-expected-fields", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
-
-		if err := reg.Run(false, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
-
-		output := captureJSONOutput(t, func() {
-			reg.ReportJSON()
-		})
-
-		var result []FileIssues
-		if err := json.Unmarshal([]byte(output), &result); err != nil {
-			t.Fatalf("failed to parse JSON: %v", err)
-		}
+func TestProjectSelection(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	multipkg := filepath.Join(cwd, "pkg", "dustat", "testdata", "multipkg")
 
-		// Verify structure
-		if len(result) == 0 {
-			t.Fatal("expected at least one file in result")
-		}
+	tests := []struct {
+		name     string
+		args     []string
+		report   string
+		root     string
+		patterns []string
+	}{
+		{"arguments", []string{"./pkg/dustat/testdata/multipkg/a"}, "", multipkg, []string{filepath.Join(multipkg, "a", "...")}},
+		{"report-only", nil, "./pkg/dustat/testdata/multipkg/a", multipkg, []string{filepath.Join(multipkg, "a", "...")}},
+		// the arguments name the project, --report replaces their selection
+		{"project-and-report", []string{"./pkg/dustat/testdata/multipkg"}, "./pkg/dustat/testdata/multipkg/a", multipkg, []string{filepath.Join(multipkg, "a", "...")}},
+		{"arguments-and-report", []string{"./pkg/dustat/testdata/multipkg/b"}, "./pkg/dustat/testdata/multipkg/a", multipkg, []string{filepath.Join(multipkg, "a", "...")}},
+	}
 
-		for _, fileIssues := range result {
-			if fileIssues.File == "" {
-				t.Error("expected file path to be non-empty")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, patterns, err := projectSelection(tt.args, tt.report)
+			if err != nil {
+				t.Fatalf("failed to resolve arguments: %v", err)
 			}
 
-			if len(fileIssues.Issues) == 0 {
-				t.Errorf("expected at least one issue for file %s", fileIssues.File)
+			if root != tt.root {
+				t.Errorf("expected root %s, got %s", tt.root, root)
 			}
-
-			for _, issue := range fileIssues.Issues {
-				if issue.Symbol == "" {
-					t.Errorf("expected symbol to be non-empty in file %s", fileIssues.File)
-				}
-				if issue.Line <= 0 {
-					t.Errorf("expected line number to be positive, got %d for symbol %s", issue.Line, issue.Symbol)
-				}
+			if !reflect.DeepEqual(patterns, tt.patterns) {
+				t.Errorf("expected patterns %v, got %v", tt.patterns, patterns)
 			}
-		}
-	})
-
-	t.Run("json-output-includes-expected-symbols", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
-
-		if err := reg.Run(false, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
-
-		output := captureJSONOutput(t, func() {
-			reg.ReportJSON()
 		})
+	}
+}
 
-		var result []FileIssues
-		if err := json.Unmarshal([]byte(output), &result); err != nil {
-			t.Fatalf("failed to parse JSON: %v", err)
-		}
-
-		// Check that UnusedStruct is in the results
-		foundUnusedStruct := false
-		for _, fileIssues := range result {
-			for _, issue := range fileIssues.Issues {
-				if issue.Symbol == "UnusedStruct" {
-					foundUnusedStruct = true
-					if issue.Line != 12 {
-						t.Errorf("expected UnusedStruct at line 12, got line %d", issue.Line)
-					}
-					break
-				}
-			}
-		}
-
-		if !foundUnusedStruct {
-			t.Error("expected to find UnusedStruct in JSON output")
-		}
-	})
-
-	t.Run("json-output-respects-ignore-list", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
-
-		ignoreList := map[string]struct{}{
-			"UnusedButIgnoredStruct": {},
+func TestProjectSelectionWithoutPatterns(t *testing.T) {
+	for _, report := range []string{"", ",", " ", " , "} {
+		if _, _, err := projectSelection(nil, report); err == nil {
+			t.Errorf("expected an error for --report=%q without arguments", report)
 		}
-		reg.WithIgnoreList(ignoreList)
+	}
+}
 
-		if err := reg.Run(false, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
+func TestIgnoreEntries(t *testing.T) {
+	config := &dustat.Config{Ignore: []string{"~^A{2,3}$", "B"}}
 
-		output := captureJSONOutput(t, func() {
-			reg.ReportJSON()
-		})
+	if entries := ignoreEntries("", false, config); !reflect.DeepEqual(entries, config.Ignore) {
+		t.Errorf("expected the entries of the config %v, got %v", config.Ignore, entries)
+	}
+	if entries := ignoreEntries("C, D", true, config); !reflect.DeepEqual(entries, []string{"C", "D"}) {
+		t.Errorf("expected the entries of the flag, got %v", entries)
+	}
+}
 
-		var result []FileIssues
-		if err := json.Unmarshal([]byte(output), &result); err != nil {
-			t.Fatalf("failed to parse JSON: %v", err)
-		}
+func TestCommonDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		dirs     []string
+		expected string
+	}{
+		{"none", nil, ""},
+		{"one", []string{"/a/b"}, "/a/b"},
+		{"nested", []string{"/a/b", "/a/b/c"}, "/a/b"},
+		{"siblings", []string{"/a/b/c", "/a/b/d"}, "/a/b"},
+		{"prefix", []string{"/a/b", "/a/bc"}, "/a"},
+	}
 
-		// Verify UnusedButIgnoredStruct is not in results
-		for _, fileIssues := range result {
-			for _, issue := range fileIssues.Issues {
-				if issue.Symbol == "UnusedButIgnoredStruct" {
-					t.Error("expected UnusedButIgnoredStruct to be ignored, but found in JSON output")
-				}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if dir := commonDir(tt.dirs); dir != filepath.FromSlash(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, dir)
 			}
-		}
-	})
-
-	t.Run("json-output-empty-when-all-ignored", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
-
-		ignoreList := map[string]struct{}{
-			"UnusedStruct":           {},
-			"UnusedButIgnoredStruct": {},
-			"MY_CONS":                {},
-		}
-		reg.WithIgnoreList(ignoreList)
-
-		if err := reg.Run(false, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
-
-		output := captureJSONOutput(t, func() {
-			reg.ReportJSON()
-		})
-
-		var result []FileIssues
-		if err := json.Unmarshal([]byte(output), &result); err != nil {
-			t.Fatalf("failed to parse JSON: %v", err)
-		}
-
-		if len(result) != 0 {
-			t.Errorf("expected empty array, got %d files", len(result))
-		}
-
-		// Verify output is literally "[]"
-		expectedOutput := "[]"
-		if output[:len(expectedOutput)] != expectedOutput {
-			t.Errorf("expected output to start with '[]', got: %s", output)
-		}
-	})
-
-	t.Run("json-issues-sorted-by-line", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
-
-		if err := reg.Run(false, false); err != nil {
-			t.Fatalf("failed to run registry: %v", err)
-		}
-
-		output := captureJSONOutput(t, func() {
-			reg.ReportJSON()
 		})
-
-		var result []FileIssues
-		if err := json.Unmarshal([]byte(output), &result); err != nil {
-			t.Fatalf("failed to parse JSON: %v", err)
-		}
-
-		// Verify issues are sorted by line number within each file
-		for _, fileIssues := range result {
-			for i := 1; i < len(fileIssues.Issues); i++ {
-				if fileIssues.Issues[i].Line < fileIssues.Issues[i-1].Line {
-					t.Errorf("issues not sorted by line number in file %s: %d came before %d",
-						fileIssues.File, fileIssues.Issues[i-1].Line, fileIssues.Issues[i].Line)
-				}
-			}
-		}
-	})
+	}
 }
 
-func TestRunProducesStdoutOutput(t *testing.T) {
-	const testProjectPath = "./test"
+func TestSinglePackageDirectory(t *testing.T) {
+	root, patterns, err := projectArgs([]string{"./pkg/dustat/testdata/multipkg/a"})
+	if err != nil {
+		t.Fatalf("failed to resolve arguments: %v", err)
+	}
 
-	t.Run("text-output-mode", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
+	reg, err := dustat.NewRegistry(root)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
 
-		output := captureJSONOutput(t, func() {
-			if err := reg.Run(true, false); err != nil {
-				t.Fatalf("Run failed: %v", err)
-			}
-		})
+	if err := reg.WithPackages(patterns...).Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
 
-		if output == "" {
-			t.Fatal("expected text output to stdout, got empty string")
+	// main.go calls a.New, b is not reported
+	for _, decl := range reg.Result {
+		if decl.Key() == "example.com/multipkg/a.New" {
+			t.Error("expected a.New to be used by main.go")
 		}
-
-		if !bytes.Contains([]byte(output), []byte("Unused Exported Symbols")) {
-			t.Errorf("expected output to contain 'Unused Exported Symbols', got: %s", output)
+		if decl.Package != "example.com/multipkg/a" {
+			t.Errorf("expected only the declarations of a, found %s", decl.Key())
 		}
-	})
+	}
+	if len(reg.Result) != 1 || reg.Result[0].Key() != "example.com/multipkg/a.Config" {
+		t.Errorf("expected only a.Config to be reported, got %v", reg.Result)
+	}
+}
 
-	t.Run("json-output-mode", func(t *testing.T) {
-		reg, err := NewRegistry(testProjectPath)
-		if err != nil {
-			t.Fatalf("failed to create registry: %v", err)
-		}
+func TestConfigPatterns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".dustat.yml")
+	if err := os.WriteFile(path, []byte("report: [./pkg/..., example.com/lib/...]\nusage-from: [../consumer]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
 
-		output := captureJSONOutput(t, func() {
-			if err := reg.Run(true, true); err != nil {
-				t.Fatalf("Run failed: %v", err)
-			}
-		})
+	config, err := dustat.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
 
-		if output == "" {
-			t.Fatal("expected JSON output to stdout, got empty string")
-		}
+	// directories are relative to the file, import paths are kept
+	want := []string{filepath.Join(dir, "pkg", "..."), "example.com/lib/..."}
+	if got := configPatterns(config, config.Report); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
 
-		var result []FileIssues
-		if err := json.Unmarshal([]byte(output), &result); err != nil {
-			t.Fatalf("expected valid JSON output, got parse error: %v\nOutput: %s", err, output)
-		}
-	})
+	want = []string{filepath.Join(filepath.Dir(dir), "consumer", "...")}
+	if got := configPatterns(config, config.UsageFrom); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
 }
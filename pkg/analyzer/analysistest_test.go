@@ -0,0 +1,33 @@
+// The go/packages loader of golang.org/x/tools v0.18.0, which analysistest
+// uses, does not build with go1.25 and later, whose token.FileSet changed.
+// Drop the constraint along with an upgrade of golang.org/x/tools.
+
+//go:build !go1.25
+
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// The expected diagnostics and facts are the want comments of the testdata
+// files.
+func TestAnalysistest(t *testing.T) {
+	results := analysistest.Run(t, "./testdata/project", Analyzer, "./...")
+
+	var messages []string
+	commands := make(map[string]bool)
+	for _, result := range results {
+		if isCommand(result.Pass) {
+			commands[result.Pass.Pkg.Path()] = true
+		}
+		for _, diagnostic := range result.Diagnostics {
+			messages = append(messages, diagnostic.Message)
+		}
+	}
+
+	checkRegistry(t, "./testdata/project", commands, strings.Join(messages, "\n"), len(messages))
+}
@@ -0,0 +1,207 @@
+// Package analyzer runs the dustat check as a go/analysis Analyzer, so that it
+// can take part in go vet -vettool, golangci-lint and other analysis drivers.
+//
+// Drivers check one package at a time, after the packages it imports. Every
+// package exports a Usage fact holding its exported declarations and the uses
+// it makes of them, along with the Usage of the packages it imports. Nothing
+// is known about a package's importers while it is checked, and no pass sees
+// the uses of every command at once, so a library declaration cannot be known
+// to be unused inside a driver. Only the declarations of main packages, which
+// nothing can import, are reported: those are the findings of dustat's
+// type-checked mode in the main packages. A driver seeing the whole program
+// can merge the facts of its main packages with Registry.AddPackageUsage to
+// get the others.
+package analyzer
+
+import (
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/tompston/dustat/pkg/dustat"
+)
+
+const doc = `report exported identifiers that are never used
+
+Only the declarations of main packages are reported, since the uses of a
+library declaration by every command are not known to any single pass.`
+
+// Analyzer reports the exported identifiers that are never used
+var Analyzer = &analysis.Analyzer{
+	Name:      "dustat",
+	Doc:       doc,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(Usage)},
+}
+
+var (
+	ignore string // comma-separated names or [location:]symbol patterns of the identifiers to ignore
+	fields bool   // also report the exported struct fields
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&ignore, "ignore", "", "comma-separated list of exported identifiers to ignore, as names or [location:]symbol glob patterns (~ for a regexp)")
+	Analyzer.Flags.BoolVar(&fields, "fields", false, "also report exported struct fields that are never read or written")
+}
+
+// Usage is the fact exported for a package: what the package and the packages
+// it imports, directly or not, contribute to the analysis.
+type Usage struct {
+	Packages []dustat.PackageUsage
+}
+
+func (*Usage) AFact() {}
+
+func (u *Usage) String() string {
+	var paths []string
+	for _, pkg := range u.Packages {
+		paths = append(paths, pkg.Path)
+	}
+	return "dustat(" + strings.Join(paths, ", ") + ")"
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if len(pass.Files) == 0 {
+		return nil, nil
+	}
+
+	// the usage of the imported packages, each package once
+	packages := make(map[string]dustat.PackageUsage)
+	for _, imp := range pass.Pkg.Imports() {
+		var usage Usage
+		if pass.ImportPackageFact(imp, &usage) {
+			for _, pkg := range usage.Packages {
+				packages[pkg.Path] = pkg
+			}
+		}
+	}
+
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name())
+	if tracked(dir) {
+		reg, err := newRegistry(dir)
+		if err != nil {
+			return nil, err
+		}
+		reg.AddTypedPackage(pass.Fset, pass.Pkg, pass.Files, pass.TypesInfo)
+
+		// only the uses of the declarations carried by the facts are kept
+		path := pass.Pkg.Path()
+		packages[path] = reg.PackageUsage(path, func(key string) bool {
+			if strings.HasPrefix(key, path+".") {
+				return true
+			}
+			for pkg := range packages {
+				if strings.HasPrefix(key, pkg+".") {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	if len(packages) == 0 {
+		return nil, nil
+	}
+
+	usage := &Usage{}
+	for _, pkg := range packages {
+		usage.Packages = append(usage.Packages, pkg)
+	}
+	sort.Slice(usage.Packages, func(i, j int) bool {
+		return usage.Packages[i].Path < usage.Packages[j].Path
+	})
+	pass.ExportPackageFact(usage)
+
+	if isCommand(pass) {
+		if pkg, ok := packages[pass.Pkg.Path()]; ok {
+			return nil, report(pass, dir, pkg)
+		}
+	}
+	return nil, nil
+}
+
+// report reports the declarations of the command that it does not use itself
+func report(pass *analysis.Pass, dir string, usage dustat.PackageUsage) error {
+	reg, err := newRegistry(dir)
+	if err != nil {
+		return err
+	}
+	reg.AddPackageUsage(usage)
+
+	if err := reg.AccumulateResult(); err != nil {
+		return err
+	}
+
+	sort.Slice(reg.Result, func(i, j int) bool {
+		if reg.Result[i].Pos.Filename != reg.Result[j].Pos.Filename {
+			return reg.Result[i].Pos.Filename < reg.Result[j].Pos.Filename
+		}
+		return reg.Result[i].Pos.Offset < reg.Result[j].Pos.Offset
+	})
+
+	files := make(map[string]*token.File)
+	for _, file := range pass.Files {
+		tf := pass.Fset.File(file.Pos())
+		files[tf.Name()] = tf
+	}
+
+	for _, decl := range reg.Result {
+		if tf, ok := files[decl.Pos.Filename]; ok {
+			pass.Reportf(tf.Pos(decl.Pos.Offset), "%s", decl.Message())
+		}
+	}
+
+	return nil
+}
+
+func newRegistry(dir string) (*dustat.Registry, error) {
+	reg, err := dustat.NewRegistry(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	reg.WithTypeCheck(true)
+	reg.WithFields(fields)
+
+	if ignore != "" {
+		names := make(map[string]struct{})
+		for _, name := range strings.Split(ignore, ",") {
+			names[strings.TrimSpace(name)] = struct{}{}
+		}
+		reg.WithIgnoreList(names)
+	}
+
+	return reg, nil
+}
+
+// isCommand reports whether the package of the pass is a main package, leaving
+// out the generated main package of a test binary
+func isCommand(pass *analysis.Pass) bool {
+	return pass.Pkg.Name() == "main" && !strings.HasSuffix(pass.Pkg.Path(), ".test")
+}
+
+// tracked reports whether the declarations of the package in dir are
+// reported, which is not the case of the standard library and of the modules
+// in the module cache.
+func tracked(dir string) bool {
+	skipped := []string{filepath.Join(build.Default.GOROOT, "src")}
+	if cache := os.Getenv("GOMODCACHE"); cache != "" {
+		skipped = append(skipped, cache)
+	} else {
+		for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+			skipped = append(skipped, filepath.Join(gopath, "pkg", "mod"))
+		}
+	}
+
+	for _, skip := range skipped {
+		if rel, err := filepath.Rel(skip, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/tompston/dustat/pkg/dustat"
+)
+
+func TestAnalyzer(t *testing.T) {
+	tests := []struct {
+		name     string
+		dir      string
+		expected []string
+	}{
+		{
+			// lib.ForA and lib.ForB are each used by a single command, and
+			// the declarations of lib are not reported by any of them
+			name:     "commands",
+			dir:      "./testdata/project",
+			expected: []string{"cmd/app/main.go:10:6: exported func Run is never used"},
+		},
+		{
+			// Helper is used by the tests of its package
+			name:     "tests",
+			dir:      "./testdata/tests",
+			expected: []string{"cmd/app/main.go:8:6: exported func Unused is never used"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, commands := runAnalyzer(t, tt.dir)
+			output := strings.Join(diagnostics, "\n")
+
+			for _, want := range tt.expected {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected a diagnostic %q, got:\n%s", want, output)
+				}
+			}
+			if len(diagnostics) != len(tt.expected) {
+				t.Errorf("expected %d diagnostics, got:\n%s", len(tt.expected), output)
+			}
+
+			checkRegistry(t, tt.dir, commands, output, len(diagnostics))
+		})
+	}
+}
+
+// checkRegistry checks that the diagnostics are the declarations of the
+// commands found by the type-checked mode of the registry
+func checkRegistry(t *testing.T, dir string, commands map[string]bool, output string, count int) {
+	t.Helper()
+
+	reg, err := dustat.NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if err := reg.WithTypeCheck(true).Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	var result []dustat.Decl
+	for _, decl := range reg.Result {
+		if commands[decl.Package] {
+			result = append(result, decl)
+		}
+	}
+
+	if len(result) != count {
+		t.Errorf("expected the %d declarations of the registry, got:\n%s", len(result), output)
+	}
+	for _, decl := range result {
+		if !strings.Contains(output, decl.Message()) {
+			t.Errorf("expected %s to be reported, got:\n%s", decl.Key(), output)
+		}
+	}
+}
+
+// runAnalyzer runs the Analyzer on the packages of the module in dir the way
+// go vet does: one package at a time, along with its test files, after the
+// packages it imports, with the facts passed on through gob. It returns the
+// diagnostics and the import paths of the main packages.
+func runAnalyzer(t *testing.T, dir string) ([]string, map[string]bool) {
+	t.Helper()
+
+	cmd := exec.Command("go", "list", "-deps", "-test", "-export", "-json", "./...")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go list failed: %v", err)
+	}
+
+	type listedPackage struct {
+		ImportPath  string
+		Name        string
+		Dir         string
+		Export      string
+		ForTest     string
+		GoFiles     []string
+		TestGoFiles []string
+		Standard    bool
+	}
+
+	var pkgs []listedPackage
+	exports := make(map[string]string)
+	for dec := json.NewDecoder(bytes.NewReader(out)); dec.More(); {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err != nil {
+			t.Fatalf("failed to decode go list output: %v", err)
+		}
+		// the test variants are checked through the package they test
+		if pkg.ForTest != "" || strings.HasSuffix(pkg.ImportPath, ".test") {
+			continue
+		}
+		exports[pkg.ImportPath] = pkg.Export
+		pkgs = append(pkgs, pkg)
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		return os.Open(exports[path])
+	})
+
+	facts := make(map[string][]byte)
+	commands := make(map[string]bool)
+	var diagnostics []string
+
+	for _, pkg := range pkgs {
+		if pkg.Standard {
+			continue
+		}
+		if pkg.Name == "main" {
+			commands[pkg.ImportPath] = true
+		}
+
+		var files []*ast.File
+		for _, name := range append(pkg.GoFiles, pkg.TestGoFiles...) {
+			file, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", name, err)
+			}
+			files = append(files, file)
+		}
+
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		conf := types.Config{Importer: imp}
+		tpkg, err := conf.Check(pkg.ImportPath, fset, files, info)
+		if err != nil {
+			t.Fatalf("failed to type-check %s: %v", pkg.ImportPath, err)
+		}
+
+		pass := &analysis.Pass{
+			Analyzer:  Analyzer,
+			Fset:      fset,
+			Files:     files,
+			Pkg:       tpkg,
+			TypesInfo: info,
+			Report: func(d analysis.Diagnostic) {
+				diagnostics = append(diagnostics, fmt.Sprintf("%s: %s", fset.Position(d.Pos), d.Message))
+			},
+			ImportPackageFact: func(pkg *types.Package, fact analysis.Fact) bool {
+				data, ok := facts[pkg.Path()]
+				if !ok {
+					return false
+				}
+				if err := gob.NewDecoder(bytes.NewReader(data)).Decode(fact); err != nil {
+					t.Fatalf("failed to decode the fact of %s: %v", pkg.Path(), err)
+				}
+				return true
+			},
+			ExportPackageFact: func(fact analysis.Fact) {
+				var buf bytes.Buffer
+				if err := gob.NewEncoder(&buf).Encode(fact); err != nil {
+					t.Fatalf("failed to encode the fact of %s: %v", pkg.ImportPath, err)
+				}
+				facts[pkg.ImportPath] = buf.Bytes()
+			},
+		}
+
+		if _, err := Analyzer.Run(pass); err != nil {
+			t.Fatalf("analyzer failed on %s: %v", pkg.ImportPath, err)
+		}
+	}
+
+	return diagnostics, commands
+}
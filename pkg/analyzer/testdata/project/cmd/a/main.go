@@ -0,0 +1,7 @@
+package main // want package:`dustat\(example.com/project/cmd/a, example.com/project/lib\)`
+
+import "example.com/project/lib"
+
+func main() {
+	lib.ForA()
+}
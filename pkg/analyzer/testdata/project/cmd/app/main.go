@@ -0,0 +1,10 @@
+package main // want package:`dustat\(example.com/project/cmd/app, example.com/project/lib\)`
+
+import "example.com/project/lib"
+
+func main() {
+	_ = lib.NewClient().Get()
+	_ = lib.Amount{}
+}
+
+func Run() {} // want `exported func Run is never used`
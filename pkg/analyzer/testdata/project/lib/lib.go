@@ -0,0 +1,32 @@
+package lib // want package:`dustat\(example.com/project/lib\)`
+
+import "fmt"
+
+type Client struct{}
+
+func (Client) Get() string { return helper() }
+
+func (Client) Delete() {}
+
+func (c Client) String() string { return fmt.Sprint(c.Get()) }
+
+func NewClient() Client { return Client{} }
+
+func Unused() {}
+
+// ForA and ForB are each used by one command
+func ForA() {}
+
+func ForB() {}
+
+// Amount is not a database/sql/driver.Valuer, its Value method returns an int
+type Amount struct{}
+
+func (Amount) Value() int { return 0 }
+
+//dustat:ignore kept for the plugin API
+func Hidden() {}
+
+const Version = "v1"
+
+func helper() string { return Version }
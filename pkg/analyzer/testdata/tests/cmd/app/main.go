@@ -0,0 +1,8 @@
+package main
+
+func main() {}
+
+// Helper is only used in the tests
+func Helper() int { return 1 }
+
+func Unused() {}
@@ -0,0 +1,9 @@
+package main
+
+import "testing"
+
+func TestHelper(t *testing.T) {
+	if Helper() != 1 {
+		t.Fatal("unexpected")
+	}
+}
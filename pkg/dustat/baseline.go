@@ -0,0 +1,148 @@
+package dustat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// baselineVersion is the version of the baseline file format
+const baselineVersion = 1
+
+// Baseline is a file recording the findings of a previous run, so that only
+// the new ones are reported.
+type Baseline struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+}
+
+// BaselineEntry identifies a finding without its position, so that it still
+// matches after the code around it moves.
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Package     string `json:"package"`
+	Symbol      string `json:"symbol"`
+	Kind        Kind   `json:"kind"`
+}
+
+// Fingerprint returns a stable identity of the declaration made of its
+// package, symbol and kind, which does not change when it moves in the file.
+func (d Decl) Fingerprint() string {
+	return fingerprint(d.Package, d.Symbol(), d.Kind)
+}
+
+func fingerprint(pkg, symbol string, kind Kind) string {
+	sum := sha256.Sum256([]byte(pkg + "\x00" + symbol + "\x00" + string(kind)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewBaseline records the given findings
+func NewBaseline(decls []Decl) *Baseline {
+	baseline := &Baseline{Version: baselineVersion, Entries: []BaselineEntry{}}
+	seen := make(map[string]struct{})
+
+	for _, decl := range decls {
+		fp := decl.Fingerprint()
+		if _, ok := seen[fp]; ok {
+			continue
+		}
+		seen[fp] = struct{}{}
+
+		baseline.Entries = append(baseline.Entries, BaselineEntry{
+			Fingerprint: fp,
+			Package:     decl.Package,
+			Symbol:      decl.Symbol(),
+			Kind:        decl.Kind,
+		})
+	}
+
+	sort.Slice(baseline.Entries, func(i, j int) bool {
+		a, b := baseline.Entries[i], baseline.Entries[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.Kind < b.Kind
+	})
+
+	return baseline
+}
+
+// LoadBaseline reads a baseline file written by Baseline.Write
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading baseline: %v", err)
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("error parsing baseline %s: %v", path, err)
+	}
+
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s", baseline.Version, path)
+	}
+
+	// entries edited by hand may leave the fingerprint out
+	for i, entry := range baseline.Entries {
+		if entry.Fingerprint == "" {
+			baseline.Entries[i].Fingerprint = fingerprint(entry.Package, entry.Symbol, entry.Kind)
+		}
+	}
+
+	return &baseline, nil
+}
+
+// Write writes the baseline to path as indented JSON
+func (b *Baseline) Write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding baseline: %v", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing baseline: %v", err)
+	}
+	return nil
+}
+
+// applyBaseline drops the findings recorded in the baseline from Result and
+// keeps the entries that no longer match any finding in StaleBaseline.
+func (reg *Registry) applyBaseline() {
+	if reg.Baseline == nil {
+		return
+	}
+
+	known := make(map[string]struct{})
+	for _, entry := range reg.Baseline.Entries {
+		known[entry.Fingerprint] = struct{}{}
+	}
+
+	found := make(map[string]struct{})
+	result := []Decl{}
+	for _, decl := range reg.Result {
+		fp := decl.Fingerprint()
+		if _, ok := known[fp]; !ok {
+			result = append(result, decl)
+			continue
+		}
+
+		found[fp] = struct{}{}
+		if decl.Category == CategoryUnused {
+			reg.TotalUnusedLoc -= decl.LineCount
+		}
+	}
+	reg.Result = result
+
+	for _, entry := range reg.Baseline.Entries {
+		if _, ok := found[entry.Fingerprint]; !ok {
+			reg.StaleBaseline = append(reg.StaleBaseline, entry)
+		}
+	}
+}
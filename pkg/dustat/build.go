@@ -0,0 +1,122 @@
+package dustat
+
+import (
+	"fmt"
+	"go/build"
+	"path/filepath"
+	"strings"
+)
+
+// BuildConfig selects the files of a package the way the go command does for
+// the given target platform and build tags. Empty fields use the defaults of
+// the go command.
+type BuildConfig struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+func (c BuildConfig) String() string {
+	goos, goarch := c.GOOS, c.GOARCH
+	if goos == "" {
+		goos = build.Default.GOOS
+	}
+	if goarch == "" {
+		goarch = build.Default.GOARCH
+	}
+
+	if len(c.Tags) == 0 {
+		return goos + "/" + goarch
+	}
+	return goos + "/" + goarch + ":" + strings.Join(c.Tags, "+")
+}
+
+// context returns the go/build context matching the configuration. Like the
+// go command, cgo is disabled when building for another platform.
+func (c BuildConfig) context() build.Context {
+	ctx := build.Default
+	if c.GOOS != "" {
+		ctx.GOOS = c.GOOS
+	}
+	if c.GOARCH != "" {
+		ctx.GOARCH = c.GOARCH
+	}
+	if ctx.GOOS != build.Default.GOOS || ctx.GOARCH != build.Default.GOARCH {
+		ctx.CgoEnabled = false
+	}
+	ctx.BuildTags = c.Tags
+	return ctx
+}
+
+// goEnv returns the environment and flags passed to the go command
+func (c BuildConfig) goEnv() ([]string, []string) {
+	var env, flags []string
+	if c.GOOS != "" {
+		env = append(env, "GOOS="+c.GOOS)
+	}
+	if c.GOARCH != "" {
+		env = append(env, "GOARCH="+c.GOARCH)
+	}
+	if len(c.Tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(c.Tags, ","))
+	}
+	return env, flags
+}
+
+// matchFile reports whether the file at path is part of the build in any of
+// the configurations.
+func matchFile(configs []BuildConfig, path string) (bool, error) {
+	dir, name := filepath.Split(path)
+	for _, config := range configs {
+		ctx := config.context()
+		match, err := ctx.MatchFile(dir, name)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ParseBuildConfigs parses a comma-separated list of goos/goarch platforms,
+// each optionally followed by extra build tags: linux/amd64,windows/amd64:integration+e2e.
+// The tags are added to every configuration.
+func ParseBuildConfigs(list string, tags []string) ([]BuildConfig, error) {
+	var configs []BuildConfig
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		platform, extra, _ := strings.Cut(entry, ":")
+		goos, goarch, ok := strings.Cut(platform, "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("invalid build configuration %q, expected goos/goarch[:tag+tag]", entry)
+		}
+
+		config := BuildConfig{GOOS: goos, GOARCH: goarch, Tags: append([]string{}, tags...)}
+		if extra != "" {
+			config.Tags = append(config.Tags, strings.Split(extra, "+")...)
+		}
+		configs = append(configs, config)
+	}
+
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no build configurations in %q", list)
+	}
+	return configs, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,243 @@
+package dustat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configNames are the names of the configuration file, looked for in the
+// project directory and its parents.
+var configNames = []string{".dustat.yml", ".dustat.yaml", ".dustat.json"}
+
+// Config is the content of a .dustat.yml or .dustat.json file. Flags given on
+// the command line override it.
+//
+//	ignore: [MyFunc, MyStruct]
+//	ignore-packages:
+//	  - github.com/org/repo/internal/legacy/**
+//	ignore-paths:
+//	  - api/**/*.pb.go
+//	exclude: [tmp, examples]
+//	report: [./pkg/...]
+//	usage-from: [./cmd/..., ../other-repo]
+//	consumers: [../service-a, ../service-b]
+//	tests: separate
+//	format: json
+//	baseline: .dustat-baseline.json
+//	max-findings: 0
+//	max-unused-lines: 500
+type Config struct {
+	Ignore         []string `json:"ignore"`           // names or [location:]symbol patterns of the identifiers to ignore
+	IgnorePackages []string `json:"ignore-packages"`  // import path globs of the packages to ignore
+	IgnorePaths    []string `json:"ignore-paths"`     // file path globs, relative to the file, of the declarations to ignore
+	Exclude        []string `json:"exclude"`          // directory globs, relative to the file, left out of the analysis
+	Report         []string `json:"report"`           // package patterns of the reported declarations, directories relative to the file
+	UsageFrom      []string `json:"usage-from"`       // directory patterns, relative to the file, the uses are collected from
+	Consumers      []string `json:"consumers"`        // checkouts of the modules using the project, relative to the file
+	Tests          string   `json:"tests"`            // count, ignore or separate
+	Format         string   `json:"format"`           // comma-separated formats, each optionally followed by :file
+	Baseline       string   `json:"baseline"`         // baseline file, relative to the file
+	FailOnFindings *bool    `json:"fail-on-findings"` // fail when any declaration is reported
+	MaxFindings    *int     `json:"max-findings"`     // fail when more declarations are reported
+	MaxUnusedLines *int     `json:"max-unused-lines"` // fail when the unused declarations span more lines
+
+	dir string // dir is the directory of the file
+}
+
+// FindConfig walks up from dir looking for a configuration file. It returns
+// nil when there is none.
+func FindConfig(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return LoadConfig(path)
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads the configuration file at path. Files ending in .json are
+// decoded as JSON, the others as YAML.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %v", err)
+	}
+
+	if filepath.Ext(path) != ".json" {
+		values, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config %s: %v", path, err)
+		}
+		if data, err = json.Marshal(values); err != nil {
+			return nil, fmt.Errorf("error parsing config %s: %v", path, err)
+		}
+	}
+
+	var config Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("error parsing config %s: %v", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	config.dir = filepath.Dir(abs)
+
+	return &config, nil
+}
+
+// Paths returns the path globs relative to the directory of the file
+func (c *Config) Paths(globs []string) []string {
+	var paths []string
+	for _, glob := range globs {
+		if !filepath.IsAbs(glob) {
+			glob = filepath.Join(c.dir, glob)
+		}
+		paths = append(paths, glob)
+	}
+	return paths
+}
+
+// parseYAML parses the subset of YAML used by the configuration file: a
+// mapping of keys to scalars or to lists of scalars, written either as
+// indented "- item" lines or inline as [a, b].
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	var list string // key of the list being filled by "- item" lines
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripYAMLComment(line), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if list == "" {
+				return nil, fmt.Errorf("line %d: list item outside of a list", i+1)
+			}
+			item := yamlScalar(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")))
+			values[list] = append(values[list].([]interface{}), item)
+			continue
+		}
+
+		if line != trimmed {
+			return nil, fmt.Errorf("line %d: nested values are not supported", i+1)
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		list = ""
+		switch {
+		case value == "":
+			values[key] = []interface{}{}
+			list = key
+
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := []interface{}{}
+			for _, item := range splitYAMLFlow(value[1 : len(value)-1]) {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, yamlScalar(item))
+				}
+			}
+			values[key] = items
+
+		default:
+			values[key] = yamlScalar(value)
+		}
+	}
+
+	return values, nil
+}
+
+// stripYAMLComment removes a # comment that is not inside a quoted string
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitYAMLFlow splits the items of a flow sequence on the commas that are not
+// inside a quoted string
+func splitYAMLFlow(value string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+// yamlScalar converts a plain scalar to a bool or a number when it looks like
+// one, and unquotes quoted strings.
+func yamlScalar(value string) interface{} {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if s, err := strconv.Unquote(value); err == nil {
+				return s
+			}
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+	}
+
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return value
+}
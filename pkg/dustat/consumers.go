@@ -0,0 +1,180 @@
+package dustat
+
+import (
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// consumerOf returns the name of the consumer the file belongs to, the path of
+// its module or the directory given in Consumers, or an empty string when the
+// file is not part of a consumer.
+func (reg *Registry) consumerOf(file string) string {
+	if len(reg.Consumers) == 0 {
+		return ""
+	}
+
+	if consumer, ok := reg.consumerFiles[file]; ok {
+		return consumer
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+
+	consumer := ""
+	for _, dir := range reg.Consumers {
+		if !within(reg.patternDir(dir), abs) {
+			continue
+		}
+
+		consumer = dir
+		if mod := reg.moduleOf(abs); mod != nil && mod.Consumer {
+			consumer = mod.Path
+		}
+		break
+	}
+
+	if reg.consumerFiles == nil {
+		reg.consumerFiles = make(map[string]string)
+	}
+	reg.consumerFiles[file] = consumer
+	return consumer
+}
+
+// consumerUse records that the consumer of file uses key
+func (reg *Registry) consumerUse(key, file string) {
+	consumer := reg.consumerOf(file)
+	if consumer == "" {
+		return
+	}
+
+	consumers, ok := reg.consumerUses[key]
+	if !ok {
+		consumers = make(map[string]struct{})
+		reg.consumerUses[key] = consumers
+	}
+	consumers[consumer] = struct{}{}
+}
+
+// keptBy returns the sorted consumers using decl
+func (reg *Registry) keptBy(decl Decl) []string {
+	seen := make(map[string]struct{})
+	for _, key := range reg.useKeys(decl) {
+		for consumer := range reg.consumerUses[key] {
+			seen[consumer] = struct{}{}
+		}
+	}
+
+	var consumers []string
+	for consumer := range seen {
+		consumers = append(consumers, consumer)
+	}
+	sort.Strings(consumers)
+
+	return consumers
+}
+
+// ownPackage reports whether the import path belongs to one of the modules of
+// the project
+func (reg *Registry) ownPackage(path string) bool {
+	for _, mod := range reg.ownModules() {
+		if path == mod.Path || strings.HasPrefix(path, mod.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// countConsumerUses counts the uses a consumer file makes of the packages of
+// the project. The names selected from an import of one of them, and the
+// unresolved identifiers of a file dot-importing one of them, are resolved
+// through its import path and counted under the Decl.Key. Methods and fields
+// are counted by name as in countUses, once the file imports the project, and
+// so are the methods of its interfaces.
+func (reg *Registry) countConsumerUses(file *ast.File, filename string) {
+	imports := make(map[string]string)
+	var dotImports []string
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || !reg.ownPackage(path) {
+			continue
+		}
+		if imp.Name != nil && imp.Name.Name == "." {
+			dotImports = append(dotImports, path)
+			continue
+		}
+		for _, name := range importSpecNames(imp) {
+			imports[name] = path
+		}
+	}
+
+	if len(imports) == 0 && len(dotImports) == 0 {
+		return
+	}
+
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			// a dot-imported name is not declared in the file
+			if n.Obj == nil && ast.IsExported(n.Name) {
+				for _, path := range dotImports {
+					reg.addUse(path+"."+n.Name, filename)
+				}
+			}
+
+		case *ast.FuncDecl:
+			// the name of a method is not a use
+			if n.Recv != nil {
+				ast.Inspect(n.Recv, visit)
+				ast.Inspect(n.Type, visit)
+				if n.Body != nil {
+					ast.Inspect(n.Body, visit)
+				}
+				return false
+			}
+
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok && x.Obj == nil {
+				if path, ok := imports[x.Name]; ok {
+					reg.addUse(path+"."+n.Sel.Name, filename)
+					return false
+				}
+			}
+			reg.addUse(memberKey(n.Sel.Name), filename)
+
+			// and neither is the selected name
+			ast.Inspect(n.X, visit)
+			return false
+
+		case *ast.InterfaceType:
+			for _, field := range n.Methods.List {
+				if fn, ok := field.Type.(*ast.FuncType); ok {
+					for _, name := range field.Names {
+						reg.addInterfaceMethod(name.Name, shapeOf(fn), filename)
+					}
+				}
+			}
+
+		case *ast.KeyValueExpr:
+			if key, ok := n.Key.(*ast.Ident); ok {
+				reg.addUse(memberKey(key.Name), filename)
+			}
+
+		case *ast.CompositeLit:
+			if len(n.Elts) > 0 {
+				if _, keyed := n.Elts[0].(*ast.KeyValueExpr); !keyed {
+					if name := literalTypeName(n.Type); name != "" {
+						reg.addUse(literalKey(name), filename)
+					}
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(file, visit)
+}
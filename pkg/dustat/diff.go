@@ -0,0 +1,159 @@
+package dustat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lineRange is an inclusive range of changed lines in a file
+type lineRange struct {
+	start, end int
+}
+
+// git runs a git command in dir and returns its output
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s failed: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// changedLines returns the lines of the working tree that changed since ref,
+// keyed by absolute file path. Untracked files are changed as a whole.
+func changedLines(dir, ref string) (map[string][]lineRange, error) {
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+
+	diff, err := git(dir, "diff", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames", ref, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := parseDiff(root, diff)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git(dir, "ls-files", "--others", "--exclude-standard", "--full-name", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(strings.TrimSpace(string(untracked)), "\n") {
+		if name != "" {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			changed[path] = []lineRange{{1, int(^uint(0) >> 1)}}
+		}
+	}
+
+	return changed, nil
+}
+
+// parseDiff collects the ranges of lines added or modified by a diff printed
+// with --unified=0. Lines removed without replacement mark the lines around
+// them as changed, so the declaration they were part of counts as touched.
+func parseDiff(root string, diff []byte) (map[string][]lineRange, error) {
+	changed := make(map[string][]lineRange)
+	var file string
+
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if name == "/dev/null" {
+				file = ""
+				continue
+			}
+			file = filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(name, "b/")))
+
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			// @@ -start,count +start,count @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+
+			startText, countText, hasCount := strings.Cut(fields[2][1:], ",")
+			start, err := strconv.Atoi(startText)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+
+			count := 1
+			if hasCount {
+				if count, err = strconv.Atoi(countText); err != nil {
+					return nil, fmt.Errorf("invalid hunk header %q", line)
+				}
+			}
+
+			if count == 0 {
+				changed[file] = append(changed[file], lineRange{start, start + 1})
+			} else {
+				changed[file] = append(changed[file], lineRange{start, start + count - 1})
+			}
+		}
+	}
+
+	return changed, scanner.Err()
+}
+
+// applySince keeps the findings whose declaration overlaps a line changed
+// since the Since git ref. The uses are still counted in the whole project.
+func (reg *Registry) applySince() error {
+	if reg.Since == "" {
+		return nil
+	}
+
+	changed, err := changedLines(reg.root(), reg.Since)
+	if err != nil {
+		return err
+	}
+
+	result := []Decl{}
+	for _, decl := range reg.Result {
+		if touched(decl, changed) {
+			result = append(result, decl)
+		} else if decl.Category == CategoryUnused {
+			reg.TotalUnusedLoc -= decl.LineCount
+		}
+	}
+	reg.Result = result
+
+	return nil
+}
+
+func touched(decl Decl, changed map[string][]lineRange) bool {
+	// git prints the paths with symbolic links resolved
+	file := decl.Pos.Filename
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+
+	for _, r := range changed[file] {
+		if decl.Pos.Line <= r.end && r.start <= decl.End.Line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1461 @@
+// Package dustat finds the exported identifiers of a Go project that are never
+// used outside of their declaration.
+//
+//	reg, err := dustat.NewRegistry("./path/to/project")
+//	if err != nil {
+//		return err
+//	}
+//	if err := reg.WithTypeCheck(true).Run(); err != nil {
+//		return err
+//	}
+//	for _, decl := range reg.Result {
+//		fmt.Println(decl.Key(), decl.Category, decl.Pos)
+//	}
+//
+// Files parsed by the caller can be analyzed without walking a directory by
+// adding them with AddFile and calling AccumulateResult.
+package dustat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind is the kind of an exported declaration
+type Kind string
+
+const (
+	KindFunc    Kind = "func"
+	KindMethod  Kind = "method"
+	KindType    Kind = "type"
+	KindConst   Kind = "const"
+	KindVar     Kind = "var"
+	KindField   Kind = "field"
+	KindPackage Kind = "package" // only used for suppression directives on a package clause
+)
+
+// Category classifies a reported declaration
+type Category string
+
+const (
+	CategoryUnused        Category = "unused"         // nothing refers to the declaration
+	CategoryInterfaceOnly Category = "interface-only" // a method only needed to satisfy an interface
+	CategoryTaggedField   Category = "tagged-field"   // an unused field with a struct tag, possibly used through reflection
+	CategoryTestOnly      Category = "test-only"      // only used from _test.go files
+	CategoryGenerated     Category = "generated"      // declared in a generated file
+	CategoryReplaceOnly   Category = "replace-only"   // only used by modules reaching it through a replace directive
+
+	// CategoryUnusedSuppression is a suppression directive that does not hide
+	// any finding. Its position is the one of the directive comment.
+	CategoryUnusedSuppression Category = "unused-suppression"
+)
+
+// TestMode selects how uses inside _test.go files are treated
+type TestMode string
+
+const (
+	TestsCount    TestMode = "count"    // uses in tests keep a declaration alive
+	TestsIgnore   TestMode = "ignore"   // uses in tests are ignored
+	TestsSeparate TestMode = "separate" // declarations only used in tests are reported as CategoryTestOnly
+)
+
+// ParseTestMode parses the value of --tests
+func ParseTestMode(mode string) (TestMode, error) {
+	switch m := TestMode(mode); m {
+	case TestsCount, TestsIgnore, TestsSeparate:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid --tests value %q, expected count, ignore or separate", mode)
+}
+
+type Decl struct {
+	Name       string
+	Recv       string // Recv is the receiver type name for methods and the struct type name for fields, empty otherwise
+	Package    string // Package is the import path of the declaring package
+	Kind       Kind
+	Category   Category
+	Implements string   // Implements names an interface satisfied by the method, when known
+	Tag        string   // Tag is the struct tag of a field
+	TestFiles  []string // TestFiles lists the _test.go files using the declaration
+	Generated  bool     // Generated is set for declarations in files with a "Code generated ... DO NOT EDIT." header
+	Directive  string   // Directive is the comment text of an unused suppression directive
+	UsedBy     []string // UsedBy lists the modules using a CategoryReplaceOnly declaration, or the consumers using a Kept one
+	Pos        token.Position
+	End        token.Position
+	LineCount  int
+}
+
+// Key returns the identity of the declaration, made of the import path of its
+// package, the receiver type (for methods) and the name, e.g.
+// github.com/org/repo/pkg.Server.Start
+func (d Decl) Key() string {
+	if d.Name == "" {
+		return d.Package
+	}
+	if d.Recv != "" {
+		return d.Package + "." + d.Recv + "." + d.Name
+	}
+	return d.Package + "." + d.Name
+}
+
+// Symbol returns the name of the declaration within its package, qualified by
+// the receiver type for methods, e.g. Server.Start
+func (d Decl) Symbol() string {
+	if d.Recv != "" {
+		return d.Recv + "." + d.Name
+	}
+	return d.Name
+}
+
+// Message describes the finding in one sentence, for the formats that
+// report each declaration on its own
+func (d Decl) Message() string {
+	switch d.Category {
+	case CategoryInterfaceOnly:
+		if d.Implements != "" {
+			return fmt.Sprintf("exported %s %s is only used to satisfy %s", d.Kind, d.Symbol(), d.Implements)
+		}
+		return fmt.Sprintf("exported %s %s is only used to satisfy an interface", d.Kind, d.Symbol())
+	case CategoryTaggedField:
+		return fmt.Sprintf("exported %s %s is never used, but has the struct tag `%s`", d.Kind, d.Symbol(), d.Tag)
+	case CategoryTestOnly:
+		return fmt.Sprintf("exported %s %s is only used in tests", d.Kind, d.Symbol())
+	case CategoryGenerated:
+		return fmt.Sprintf("exported %s %s in a generated file is never used", d.Kind, d.Symbol())
+	case CategoryReplaceOnly:
+		return fmt.Sprintf("exported %s %s is only used through a replace directive, by %s", d.Kind, d.Symbol(), strings.Join(d.UsedBy, ", "))
+	case CategoryUnusedSuppression:
+		return fmt.Sprintf("suppression directive %s does not hide any finding", d.Directive)
+	}
+	return fmt.Sprintf("exported %s %s is never used", d.Kind, d.Symbol())
+}
+
+// severity is "warning" for the declarations that can be removed and the
+// stale suppressions, and "info" for the findings that need a closer look
+func (d Decl) severity() string {
+	switch d.Category {
+	case CategoryUnused, CategoryUnusedSuppression:
+		return "warning"
+	}
+	return "info"
+}
+
+type Registry struct {
+	Path            string                         // Path is the root path of the project being analyzed
+	Ignore          map[string]struct{}            // Ignore holds names or [location:]symbol patterns of identifiers that should be ignored in the analysis
+	IgnorePackages  []string                       // IgnorePackages are globs of import paths whose declarations are not reported
+	IgnorePaths     []string                       // IgnorePaths are globs of files or directories whose declarations are not reported, relative to Path
+	Exclude         []string                       // Exclude are globs of directories left out of the analysis, relative to Path
+	Packages        []string                       // Packages are package patterns selecting the reported declarations, all of them when empty
+	UsageFrom       []string                       // UsageFrom are directory patterns of the packages the uses are collected from, see WithUsageFrom
+	Consumers       []string                       // Consumers are the directories of the projects using this one, see WithConsumers
+	Declarations    map[string]Decl                // Declarations holds all exported identifiers found in the project, keyed by Decl.Key
+	UsageCount      map[string]int                 // UsageCount tracks how many times each identifier is used outside of tests, excluding its declaration
+	TestUsage       map[string]map[string]struct{} // TestUsage holds the _test.go files using each identifier
+	Result          []Decl                         // Result holds the final unused declarations
+	Kept            []Decl                         // Kept holds the declarations used by the Consumers, with the consumers using them in UsedBy
+	TotalUnusedLoc  int                            // TotalUnusedLoc counts the total number of unused lines across all unused declarations
+	TypeCheck       bool                           // TypeCheck resolves identifiers to their declarations with go/types instead of matching names
+	TypeErrors      []error                        // TypeErrors holds the type errors tolerated in the type-checked mode
+	InterfaceOnly   bool                           // InterfaceOnly reports methods only used to satisfy an interface instead of treating them as used
+	Fields          bool                           // Fields also collects the exported fields of struct types
+	Tests           TestMode                       // Tests selects how uses inside _test.go files are treated
+	BuildConfigs    []BuildConfig                  // BuildConfigs select the analyzed files, the results of several configurations are combined
+	ReportGenerated bool                           // ReportGenerated reports declarations in generated files as CategoryGenerated instead of skipping them
+	FileSet         *token.FileSet                 // FileSet records the positions of the parsed files, created on first use when nil
+	Filters         []func(Decl) bool              // Filters drop the declarations for which one of them returns false from Result
+
+	// InterfaceMethods maps the keys of methods that satisfy an interface to
+	// the name of that interface. Only filled in the type-checked mode.
+	InterfaceMethods map[string]string
+
+	// ReportUnusedSuppressions adds the suppression directives that do not
+	// hide any finding to the result, as CategoryUnusedSuppression.
+	ReportUnusedSuppressions bool
+
+	// Baseline holds the findings of a previous run, which are dropped from
+	// Result. Its entries that no longer match anything are kept in StaleBaseline.
+	Baseline      *Baseline
+	StaleBaseline []BaselineEntry
+
+	// Since is a git ref, when set only the declarations overlapping a line
+	// changed since then are reported
+	Since string
+
+	// Modules are the Go modules found by ParseFiles and ParseFilesTyped: the
+	// module containing Path, the modules nested in it and the modules of its
+	// go.work workspace. A use only keeps the declarations of the modules its
+	// own module can reach alive.
+	Modules []*Module
+
+	modules     map[string]*Module        // Modules by module path
+	fileModules map[string]*Module        // the module of each file and directory looked up
+	moduleUses  map[string]map[string]int // uses outside of tests by key, then by module path
+	usageDirs   []string                  // workspace modules and UsageFrom directories outside of Path, only walked for uses
+	usageFiles  map[string]bool           // whether the uses of each file are counted, see countsUses
+
+	consumerFiles map[string]string              // the consumer of each file looked up, see consumerOf
+	consumerUses  map[string]map[string]struct{} // the consumers using each key
+
+	suppressions    map[string]*suppression // suppression directives by position
+	suppressedDecls map[string]*suppression // directives covering a declaration, by Decl.Key
+	suppressedPkgs  map[string]*suppression // directives on a package clause, by import path
+
+	methodShapes map[string]funcShape // the shape of each method, by Decl.Key, see implements
+}
+
+func NewRegistry(path string) (*Registry, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path cannot be empty")
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("path does not exist: %s", path)
+	}
+
+	return &Registry{
+		Declarations: make(map[string]Decl),
+		UsageCount:   make(map[string]int),
+		TestUsage:    make(map[string]map[string]struct{}),
+		Tests:        TestsCount,
+		Ignore:       make(map[string]struct{}),
+		Result:       []Decl{},
+		Path:         path,
+
+		InterfaceMethods: make(map[string]string),
+
+		moduleUses:   make(map[string]map[string]int),
+		consumerUses: make(map[string]map[string]struct{}),
+	}, nil
+}
+
+// WithIgnoreList hides the declarations matching one of the names or
+// [location:]symbol patterns, see ignorePattern.
+func (reg *Registry) WithIgnoreList(ignore map[string]struct{}) *Registry {
+	if ignore == nil {
+		ignore = make(map[string]struct{})
+	}
+
+	reg.Ignore = ignore
+	return reg
+}
+
+// WithIgnorePackages hides the declarations of the packages whose import path
+// matches one of the globs, e.g. github.com/org/repo/internal/**
+func (reg *Registry) WithIgnorePackages(globs ...string) *Registry {
+	reg.IgnorePackages = globs
+	return reg
+}
+
+// WithIgnorePaths hides the declarations of the files matching one of the
+// globs, or found in a directory matching one of them.
+func (reg *Registry) WithIgnorePaths(globs ...string) *Registry {
+	reg.IgnorePaths = globs
+	return reg
+}
+
+// WithExclude leaves the directories matching one of the globs out of the
+// analysis, neither their declarations nor their uses are looked at.
+func (reg *Registry) WithExclude(globs ...string) *Registry {
+	reg.Exclude = globs
+	return reg
+}
+
+// WithPackages only reports the declarations of the packages matching one of
+// the patterns of the go command: ./..., ./internal/..., an import path. The
+// uses are still counted in every package under Path.
+func (reg *Registry) WithPackages(patterns ...string) *Registry {
+	reg.Packages = patterns
+	return reg
+}
+
+// WithUsageFrom collects the uses from the packages in the directories
+// matching one of the patterns, ./cmd/... or ../other-repo/..., which may be
+// outside of Path. Only their declarations under Path can be reported. Once
+// set, the uses are only collected from these packages and from the reported
+// ones, every package under Path unless WithPackages selects some.
+func (reg *Registry) WithUsageFrom(patterns ...string) *Registry {
+	reg.UsageFrom = patterns
+	return reg
+}
+
+// WithConsumers only counts the uses found in the checkouts of the projects
+// using this one, in the directories given, which are relative to Path. In
+// the default mode their references are resolved through the import paths of
+// the modules of Path. The declarations they use are listed in Kept, along with
+// the consumers using them.
+func (reg *Registry) WithConsumers(dirs ...string) *Registry {
+	reg.Consumers = dirs
+	return reg
+}
+
+// WithTypeCheck switches the analysis to the type-checked mode, where a
+// declaration is only used when an identifier resolves to its object.
+func (reg *Registry) WithTypeCheck(typeCheck bool) *Registry {
+	reg.TypeCheck = typeCheck
+	return reg
+}
+
+// WithInterfaceOnly lists the methods that are only used to satisfy an
+// interface as CategoryInterfaceOnly, instead of treating them as used.
+func (reg *Registry) WithInterfaceOnly(interfaceOnly bool) *Registry {
+	reg.InterfaceOnly = interfaceOnly
+	return reg
+}
+
+// WithFields also reports the exported struct fields that are never read or
+// written. Unused fields with a struct tag are reported as CategoryTaggedField.
+func (reg *Registry) WithFields(fields bool) *Registry {
+	reg.Fields = fields
+	return reg
+}
+
+// WithTests selects how uses inside _test.go files are treated
+func (reg *Registry) WithTests(mode TestMode) *Registry {
+	reg.Tests = mode
+	return reg
+}
+
+// WithBuildConfigs selects the files to analyze by their build constraints.
+// With several configurations a declaration is only unused when it is unused
+// in every one of them. Without any, the default build of the host is used.
+func (reg *Registry) WithBuildConfigs(configs ...BuildConfig) *Registry {
+	reg.BuildConfigs = configs
+	return reg
+}
+
+// WithGenerated reports the unused declarations of generated files as
+// CategoryGenerated. They are skipped by default, while the uses inside
+// generated files are always counted.
+func (reg *Registry) WithGenerated(generated bool) *Registry {
+	reg.ReportGenerated = generated
+	return reg
+}
+
+// WithUnusedSuppressions reports the //dustat:ignore and //nolint:dustat
+// directives that do not hide any finding, so that stale ones can be removed.
+func (reg *Registry) WithUnusedSuppressions(report bool) *Registry {
+	reg.ReportUnusedSuppressions = report
+	return reg
+}
+
+// WithBaseline only reports the findings that are not recorded in the baseline
+func (reg *Registry) WithBaseline(baseline *Baseline) *Registry {
+	reg.Baseline = baseline
+	return reg
+}
+
+// WithSince only reports the declarations overlapping the lines changed in the
+// working tree since the git ref. The uses are still counted in the whole
+// project.
+func (reg *Registry) WithSince(ref string) *Registry {
+	reg.Since = ref
+	return reg
+}
+
+// WithFileSet makes the registry parse files into fset, the one the files
+// given to AddFile were parsed with.
+func (reg *Registry) WithFileSet(fset *token.FileSet) *Registry {
+	reg.FileSet = fset
+	return reg
+}
+
+// WithFilter only reports the declarations for which filter returns true
+func (reg *Registry) WithFilter(filter func(Decl) bool) *Registry {
+	reg.Filters = append(reg.Filters, filter)
+	return reg
+}
+
+func (reg *Registry) fileSet() *token.FileSet {
+	if reg.FileSet == nil {
+		reg.FileSet = token.NewFileSet()
+	}
+	return reg.FileSet
+}
+
+// filtered reports whether one of the Filters drops decl
+func (reg *Registry) filtered(decl Decl) bool {
+	for _, filter := range reg.Filters {
+		if !filter(decl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (reg *Registry) buildConfigs() []BuildConfig {
+	if len(reg.BuildConfigs) == 0 {
+		return []BuildConfig{{}}
+	}
+	return reg.BuildConfigs
+}
+
+// Run analyzes the project and reports the result to each of the outputs
+func (reg *Registry) Run(outputs ...Output) error {
+	parse := reg.ParseFiles
+	if reg.TypeCheck {
+		parse = reg.ParseFilesTyped
+	}
+
+	if err := parse(); err != nil {
+		return fmt.Errorf("error parsing project: %v", err)
+	}
+
+	if err := reg.AccumulateResult(); err != nil {
+		return fmt.Errorf("error accumulating results: %v", err)
+	}
+
+	for _, output := range outputs {
+		if err := reg.Report(output.Reporter, output.Writer); err != nil {
+			return fmt.Errorf("error writing report: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ParseFiles parses the Go files under Path, matching the names of the used
+// identifiers to the declarations. The files of the workspace modules and of
+// the UsageFrom directories outside of Path are parsed too, for their uses only.
+func (reg *Registry) ParseFiles() error {
+	if err := reg.loadModules(); err != nil {
+		return err
+	}
+
+	if err := reg.parseDir(reg.Path, ""); err != nil {
+		return err
+	}
+	for _, dir := range reg.usageDirs {
+		if err := reg.parseDir(dir, reg.root()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDir parses the Go files under root, leaving out the directory skip
+func (reg *Registry) parseDir(root, skip string) error {
+	fset := reg.fileSet()
+
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && path != root && (skipDir(info.Name()) || reg.excluded(path) || path == skip) {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".go") {
+			return nil
+		}
+
+		if match, err := matchFile(reg.buildConfigs(), path); err != nil || !match {
+			return err
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.AllErrors|parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("error parsing file %s: %v", path, err)
+		}
+
+		reg.AddFile(file, path, reg.importPathOf(filepath.Dir(path)))
+		return nil
+	}); err != nil {
+		return fmt.Errorf("error walking project: %v", err)
+	}
+
+	return nil
+}
+
+// AddFile adds the declarations and the uses of a file parsed with the
+// comments into FileSet, with the import path of its package. Uses are matched
+// by name, as in ParseFiles.
+func (reg *Registry) AddFile(file *ast.File, filename, pkgPath string) {
+	// declarations in _test.go files are not part of the package API
+	var declared map[*ast.Ident]struct{}
+	if !isTestFile(filename) {
+		declared = reg.collectDecls(file, pkgPath, reg.fileSet())
+	}
+
+	if reg.consumerOf(filename) != "" {
+		reg.countConsumerUses(file, filename)
+		return
+	}
+	reg.countUses(file, filename, declared)
+}
+
+func isTestFile(path string) bool {
+	return strings.HasSuffix(path, "_test.go")
+}
+
+// addUse records a use of key found in file. Uses inside _test.go files are
+// kept apart in TestUsage, along with the files they come from.
+func (reg *Registry) addUse(key, file string) {
+	if !reg.countsUses(file) {
+		return
+	}
+	reg.consumerUse(key, file)
+
+	if !isTestFile(file) {
+		reg.UsageCount[key]++
+		reg.moduleUse(key, file)
+		return
+	}
+
+	files, ok := reg.TestUsage[key]
+	if !ok {
+		files = make(map[string]struct{})
+		reg.TestUsage[key] = files
+	}
+	files[file] = struct{}{}
+}
+
+// countsUses reports whether the uses found in file are counted. With
+// Consumers set, only those of the consumers are. With UsageFrom set, only
+// those of its packages and of the reported packages are.
+func (reg *Registry) countsUses(file string) bool {
+	if len(reg.Consumers) > 0 {
+		return reg.consumerOf(file) != ""
+	}
+
+	if len(reg.UsageFrom) == 0 {
+		return true
+	}
+
+	if counted, ok := reg.usageFiles[file]; ok {
+		return counted
+	}
+
+	dir := filepath.Dir(file)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	counted := reg.matchDir(reg.UsageFrom, dir)
+	if !counted && within(reg.root(), dir) {
+		counted = len(reg.Packages) == 0 || reg.selected(reg.importPathOf(dir), dir)
+	}
+
+	if reg.usageFiles == nil {
+		reg.usageFiles = make(map[string]bool)
+	}
+	reg.usageFiles[file] = counted
+	return counted
+}
+
+// countUses counts the identifiers of a file by name, skipping the declaring
+// identifiers. A name selected from a value (x.Name) can only be a method or a
+// field, so it is counted under memberKey instead of the bare name, unless x
+// may be an imported package.
+func (reg *Registry) countUses(file *ast.File, filename string, declared map[*ast.Ident]struct{}) {
+	imports := importNames(file)
+
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, visit)
+
+			// identifiers of locals are resolved by the parser, packages never are
+			x, isIdent := n.X.(*ast.Ident)
+			unresolved := isIdent && x.Obj == nil
+			isImport := false
+			if unresolved {
+				_, isImport = imports[x.Name]
+			}
+
+			switch {
+			case isImport:
+				reg.addUse(n.Sel.Name, filename)
+			case unresolved:
+				// could be a package we failed to name, count both ways
+				reg.addUse(n.Sel.Name, filename)
+				reg.addUse(memberKey(n.Sel.Name), filename)
+			default:
+				reg.addUse(memberKey(n.Sel.Name), filename)
+			}
+			return false
+
+		case *ast.KeyValueExpr:
+			// the key of a composite literal is either a field or a constant
+			if key, ok := n.Key.(*ast.Ident); ok {
+				reg.addUse(key.Name, filename)
+				reg.addUse(memberKey(key.Name), filename)
+				ast.Inspect(n.Value, visit)
+				return false
+			}
+
+		case *ast.CompositeLit:
+			// a literal without keys sets every field of its type
+			if len(n.Elts) > 0 {
+				if _, keyed := n.Elts[0].(*ast.KeyValueExpr); !keyed {
+					if name := literalTypeName(n.Type); name != "" {
+						reg.addUse(literalKey(name), filename)
+					}
+				}
+			}
+
+		case *ast.InterfaceType:
+			for _, field := range n.Methods.List {
+				if fn, ok := field.Type.(*ast.FuncType); ok {
+					for _, name := range field.Names {
+						reg.addInterfaceMethod(name.Name, shapeOf(fn), filename)
+					}
+				}
+				ast.Inspect(field.Type, visit)
+			}
+			return false
+
+		case *ast.StructType:
+			// field names are not uses of anything, only their types are
+			for _, field := range n.Fields.List {
+				ast.Inspect(field.Type, visit)
+			}
+			return false
+
+		case *ast.Ident:
+			if _, isDecl := declared[n]; !isDecl {
+				reg.addUse(n.Name, filename)
+			}
+		}
+		return true
+	}
+
+	ast.Inspect(file, visit)
+}
+
+// addInterfaceMethod records a method declared by an interface type in file.
+// Interfaces are not uses, they are recorded from every file.
+func (reg *Registry) addInterfaceMethod(name string, shape funcShape, file string) {
+	if !isTestFile(file) {
+		reg.UsageCount[interfaceKey(name, shape)]++
+	}
+}
+
+// memberKey is the UsageCount key for names selected from a value, which can
+// only refer to methods or fields.
+func memberKey(name string) string {
+	return "." + name
+}
+
+// literalKey is the UsageCount key for literals of the named type that set its
+// fields without naming them.
+func literalKey(typeName string) string {
+	return "literal." + typeName
+}
+
+// literalTypeName returns the name of the type of a composite literal:
+// pkg.Point{} -> Point, Pair[K, V]{} -> Pair
+func literalTypeName(expr ast.Expr) string {
+	if sel, ok := expr.(*ast.SelectorExpr); ok {
+		return sel.Sel.Name
+	}
+	return recvTypeName(expr)
+}
+
+// importNames returns the names under which the imports of a file are
+// referenced. Without type information the package name is guessed from the
+// last element of the import path.
+func importNames(file *ast.File) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, imp := range file.Imports {
+		for _, name := range importSpecNames(imp) {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// importSpecNames returns the names an import may be referenced under, see
+// importNames
+func importSpecNames(imp *ast.ImportSpec) []string {
+	if imp.Name != nil {
+		return []string{imp.Name.Name}
+	}
+
+	path := strings.Trim(imp.Path.Value, `"`)
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+
+	// major version suffixes are not part of the package name: foo/v2, yaml.v3
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+
+	return []string{name, strings.TrimSuffix(strings.TrimPrefix(name, "go-"), "-go")}
+}
+
+// collectDecls records the exported declarations of a file that belongs to the
+// package with the given import path. The identifiers naming the declarations
+// are returned, so that they are not counted as usage of themselves.
+// Suppression directives are looked for in the doc and line comments of each
+// declaration, of its group and of the package clause.
+func (reg *Registry) collectDecls(file *ast.File, pkgPath string, fset *token.FileSet) map[*ast.Ident]struct{} {
+	declared := make(map[*ast.Ident]struct{})
+	generated := isGenerated(file)
+	directives := newFileDirectives(file, fset)
+
+	if comment := directives.find(file.Package, file.Doc); comment != nil {
+		reg.suppressPackage(pkgPath, reg.addSuppression(comment, fset, pkgPath))
+	}
+
+	add := func(ident *ast.Ident, kind Kind, recv string, end token.Pos, tag string, docs ...*ast.CommentGroup) Decl {
+		decl := makeDecl(ident.Name, ident.Pos(), end, fset)
+		decl.Generated = generated
+		decl.Kind = kind
+		decl.Recv = recv
+		decl.Package = pkgPath
+		decl.Tag = tag
+		reg.Declarations[decl.Key()] = decl
+		declared[ident] = struct{}{}
+
+		if comment := directives.find(ident.Pos(), docs...); comment != nil {
+			reg.suppress(decl, reg.addSuppression(comment, fset, pkgPath))
+		}
+		return decl
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Name.IsExported() {
+				if d.Recv != nil && len(d.Recv.List) > 0 {
+					method := add(d.Name, KindMethod, recvTypeName(d.Recv.List[0].Type), d.End(), "", d.Doc)
+
+					if reg.methodShapes == nil {
+						reg.methodShapes = make(map[string]funcShape)
+					}
+					reg.methodShapes[method.Key()] = shapeOf(d.Type)
+				} else {
+					add(d.Name, KindFunc, "", d.End(), "", d.Doc)
+				}
+			}
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						add(s.Name, KindType, "", s.End(), "", s.Doc, s.Comment, d.Doc)
+					}
+
+					// embedded fields are not collected, they are used through
+					// their promoted fields and methods
+					if st, ok := s.Type.(*ast.StructType); ok && reg.Fields {
+						for _, field := range st.Fields.List {
+							tag := ""
+							if field.Tag != nil {
+								tag, _ = strconv.Unquote(field.Tag.Value)
+							}
+
+							for _, name := range field.Names {
+								if name.IsExported() {
+									add(name, KindField, s.Name.Name, field.End(), tag, field.Doc, field.Comment)
+								}
+							}
+						}
+					}
+
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+
+					for _, name := range s.Names {
+						if name.IsExported() {
+							add(name, kind, "", s.End(), "", s.Doc, s.Comment, d.Doc)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return declared
+}
+
+// isGenerated reports whether the file has the comment marking generated code,
+// https://go.dev/s/generatedcode, before its package clause.
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+
+		for _, comment := range group.List {
+			text := comment.Text
+			if strings.HasPrefix(text, "// Code generated ") && strings.HasSuffix(text, " DO NOT EDIT.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recvTypeName returns the name of the type in a method receiver expression,
+// stripping pointers and type parameters: *Set[T] -> Set
+func recvTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return recvTypeName(t.X)
+	case *ast.ParenExpr:
+		return recvTypeName(t.X)
+	case *ast.IndexExpr:
+		return recvTypeName(t.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(t.X)
+	}
+	return ""
+}
+
+// AccumulateResult fills Result with the parsed declarations that are not used
+func (reg *Registry) AccumulateResult() error {
+	patterns, err := compileIgnorePatterns(reg.Ignore)
+	if err != nil {
+		return err
+	}
+
+	for _, decl := range reg.Declarations {
+		if reg.ignored(decl, patterns) || reg.filtered(decl) || reg.usageOnly(decl) || !reg.reported(decl) {
+			continue
+		}
+
+		uses, testFiles := reg.uses(decl)
+		if uses > 0 || (len(testFiles) > 0 && reg.Tests == TestsCount) {
+			if len(reg.Consumers) > 0 {
+				decl.UsedBy = reg.keptBy(decl)
+				reg.Kept = append(reg.Kept, decl)
+			}
+			continue
+		}
+
+		if usedBy := reg.replacedBy(decl); len(usedBy) > 0 {
+			decl.Category = CategoryReplaceOnly
+			decl.UsedBy = usedBy
+		} else if iface, ok := reg.implements(decl); ok {
+			if !reg.InterfaceOnly {
+				continue
+			}
+			decl.Category = CategoryInterfaceOnly
+			decl.Implements = iface
+		} else {
+			decl.TestFiles = testFiles
+
+			switch {
+			case len(testFiles) > 0 && reg.Tests == TestsSeparate:
+				decl.Category = CategoryTestOnly
+			case decl.Kind == KindField && decl.Tag != "":
+				decl.Category = CategoryTaggedField
+			default:
+				decl.Category = CategoryUnused
+			}
+		}
+
+		// generated code cannot be fixed by hand, so it is only reported on
+		// request and kept out of the totals
+		if decl.Generated {
+			if !reg.ReportGenerated {
+				continue
+			}
+			decl.Category = CategoryGenerated
+		}
+
+		if sup := reg.suppressed(decl); sup != nil {
+			sup.used = true
+			continue
+		}
+
+		if decl.Category == CategoryUnused {
+			reg.TotalUnusedLoc += decl.LineCount
+		}
+		reg.Result = append(reg.Result, decl)
+	}
+
+	if reg.ReportUnusedSuppressions {
+		reg.Result = append(reg.Result, reg.unusedSuppressions()...)
+	}
+
+	reg.applyBaseline()
+
+	if err := reg.applySince(); err != nil {
+		return fmt.Errorf("error reading changes since %s: %v", reg.Since, err)
+	}
+
+	return nil
+}
+
+// useKeys returns the keys the uses of decl are counted under: the bare
+// identifier in the default mode, and the declaration key in the type-checked
+// mode.
+func (reg *Registry) useKeys(decl Decl) []string {
+	switch {
+	case reg.TypeCheck:
+		return []string{decl.Key()}
+	case decl.Kind == KindMethod:
+		return []string{memberKey(decl.Name)}
+	case decl.Kind == KindField:
+		return []string{memberKey(decl.Name), literalKey(decl.Recv)}
+	case len(reg.Consumers) > 0:
+		// resolved through the import path, see countConsumerUses
+		return []string{decl.Key()}
+	}
+	return []string{decl.Name}
+}
+
+// uses returns how many times decl is used outside of tests, and the sorted
+// _test.go files using it. Only the uses from modules reaching the module of
+// decl directly are counted.
+func (reg *Registry) uses(decl Decl) (int, []string) {
+	declaring := reg.moduleOf(decl.Pos.Filename)
+
+	count := 0
+	files := make(map[string]struct{})
+	for _, key := range reg.useKeys(decl) {
+		count += reg.UsageCount[key]
+		for user, n := range reg.moduleUses[key] {
+			if reg.reach(user, declaring) != reachDirect {
+				count -= n
+			}
+		}
+
+		for file := range reg.TestUsage[key] {
+			if mod := reg.moduleOf(file); mod == nil || reg.reach(mod.Path, declaring) == reachDirect {
+				files[file] = struct{}{}
+			}
+		}
+	}
+
+	var testFiles []string
+	for file := range files {
+		testFiles = append(testFiles, file)
+	}
+	sort.Strings(testFiles)
+
+	return count, testFiles
+}
+
+// replacedBy returns the sorted paths of the modules using decl outside of
+// tests through a replace directive
+func (reg *Registry) replacedBy(decl Decl) []string {
+	declaring := reg.moduleOf(decl.Pos.Filename)
+
+	seen := make(map[string]struct{})
+	for _, key := range reg.useKeys(decl) {
+		for user := range reg.moduleUses[key] {
+			if reg.reach(user, declaring) == reachReplace {
+				seen[user] = struct{}{}
+			}
+		}
+	}
+
+	var users []string
+	for user := range seen {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	return users
+}
+
+// usageOnly reports whether decl is in a directory whose files are only parsed
+// for their uses: outside of Path, or in a consumer module wherever it is
+func (reg *Registry) usageOnly(decl Decl) bool {
+	if mod := reg.moduleOf(decl.Pos.Filename); mod != nil && mod.Consumer {
+		return true
+	}
+
+	if within(reg.root(), decl.Pos.Filename) {
+		return false
+	}
+
+	for _, dir := range reg.usageDirs {
+		if within(dir, decl.Pos.Filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// walked reports whether the files in dir are part of the analysis: under
+// Path or in one of the directories parsed for their uses
+func (reg *Registry) walked(dir string) bool {
+	if within(reg.root(), dir) {
+		return true
+	}
+
+	for _, usageDir := range reg.usageDirs {
+		if within(usageDir, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonSchemaVersion is the version of the document written by the json reporter. It
+// is raised when a field is removed or changes meaning.
+const jsonSchemaVersion = 1
+
+// JSONReport is the document written by the json reporter
+type JSONReport struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Summary       Summary         `json:"summary"`
+	Findings      []Issue         `json:"findings"`
+	Kept          []Issue         `json:"kept,omitempty"`
+	StaleBaseline []BaselineEntry `json:"staleBaseline,omitempty"`
+}
+
+// Summary holds the totals of an analysis
+type Summary struct {
+	Declarations     int `json:"declarations"`     // exported declarations scanned in the reported packages
+	Findings         int `json:"findings"`         // reported declarations, of every category
+	Unused           int `json:"unused"`           // reported declarations of CategoryUnused
+	TotalUnusedLines int `json:"totalUnusedLines"` // lines spanned by the CategoryUnused declarations
+}
+
+type Issue struct {
+	Package     string   `json:"package"`
+	Symbol      string   `json:"symbol"`
+	Name        string   `json:"name"`
+	Receiver    string   `json:"receiver,omitempty"`
+	Kind        Kind     `json:"kind"`
+	Category    Category `json:"category"`
+	Implements  string   `json:"implements,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+	TestFiles   []string `json:"testFiles,omitempty"`
+	Directive   string   `json:"directive,omitempty"`
+	UsedBy      []string `json:"usedBy,omitempty"`
+	Fingerprint string   `json:"fingerprint"`
+	Location    Location `json:"location"`
+}
+
+// Location is the span of a declaration. Lines and columns start at 1, the end
+// is the position right after the declaration.
+type Location struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	LineCount int    `json:"lineCount"`
+}
+
+// Summary returns the totals of the last run
+func (reg *Registry) Summary() Summary {
+	summary := Summary{
+		Findings:         len(reg.Result),
+		TotalUnusedLines: reg.TotalUnusedLoc,
+	}
+	// the declarations only parsed for their uses are not scanned
+	for _, decl := range reg.Declarations {
+		if !reg.usageOnly(decl) && reg.reported(decl) {
+			summary.Declarations++
+		}
+	}
+	for _, decl := range reg.Result {
+		if decl.Category == CategoryUnused {
+			summary.Unused++
+		}
+	}
+	return summary
+}
+
+// Issues returns the result as issues, sorted by file and by position within
+// each file
+func (reg *Registry) Issues() []Issue {
+	return issuesOf(reg.Result)
+}
+
+func issuesOf(decls []Decl) []Issue {
+	issues := []Issue{}
+	for _, file := range groupByFile(decls) {
+		for _, decl := range file.decls {
+			issues = append(issues, Issue{
+				Package:     decl.Package,
+				Symbol:      decl.Symbol(),
+				Name:        decl.Name,
+				Receiver:    decl.Recv,
+				Kind:        decl.Kind,
+				Category:    decl.Category,
+				Implements:  decl.Implements,
+				Tag:         decl.Tag,
+				TestFiles:   decl.TestFiles,
+				Directive:   decl.Directive,
+				UsedBy:      decl.UsedBy,
+				Fingerprint: decl.Fingerprint(),
+				Location: Location{
+					File:      decl.Pos.Filename,
+					Line:      decl.Pos.Line,
+					Column:    decl.Pos.Column,
+					EndLine:   decl.End.Line,
+					EndColumn: decl.End.Column,
+					LineCount: decl.LineCount,
+				},
+			})
+		}
+	}
+	return issues
+}
+
+// Report writes the result with the reporter
+func (reg *Registry) Report(reporter Reporter, w io.Writer) error {
+	return reporter.Report(reg, w)
+}
+
+// writeText writes the result as text, in sections by category
+func (reg *Registry) writeText(w io.Writer) error {
+	var out bytes.Buffer
+
+	if len(reg.Result) > 0 {
+
+		// sort ascending by the number of lines in the declaration
+		result := append([]Decl{}, reg.Result...)
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].LineCount != result[j].LineCount {
+				return result[i].LineCount < result[j].LineCount
+			}
+			return result[i].Key() < result[j].Key()
+		})
+
+		var symbols, methods, fields, taggedFields, interfaceOnly, testOnly, replaceOnly, generated, suppressions []Decl
+		for _, decl := range result {
+			switch {
+			case decl.Category == CategoryReplaceOnly:
+				replaceOnly = append(replaceOnly, decl)
+			case decl.Category == CategoryUnusedSuppression:
+				suppressions = append(suppressions, decl)
+			case decl.Category == CategoryGenerated:
+				generated = append(generated, decl)
+			case decl.Category == CategoryTestOnly:
+				testOnly = append(testOnly, decl)
+			case decl.Category == CategoryInterfaceOnly:
+				interfaceOnly = append(interfaceOnly, decl)
+			case decl.Category == CategoryTaggedField:
+				taggedFields = append(taggedFields, decl)
+			case decl.Kind == KindMethod:
+				methods = append(methods, decl)
+			case decl.Kind == KindField:
+				fields = append(fields, decl)
+			default:
+				symbols = append(symbols, decl)
+			}
+		}
+
+		title := "Unused Exported Symbols:"
+		if reg.Tests != TestsCount {
+			title = "Unused Exported Symbols (ignoring test-only usage):"
+		}
+
+		printSection(&out, title, symbols)
+		printSection(&out, "Unused Exported Methods:", methods)
+		printSection(&out, "Unused Exported Fields:", fields)
+		printSection(&out, "Unused Exported Fields With Struct Tags (may be used through reflection):", taggedFields)
+		printSection(&out, "Exported Methods Only Used To Satisfy An Interface:", interfaceOnly)
+		printSection(&out, "Exported Symbols Only Used In Tests:", testOnly)
+		printSection(&out, "Exported Symbols Only Used Through A Replace Directive:", replaceOnly)
+		printSection(&out, "Unused Exported Symbols In Generated Files:", generated)
+		printSection(&out, "Unused Suppression Directives:", suppressions)
+
+		fmt.Fprintf(&out, "Total Unused Lines: %d, Declarations: %v\n", reg.TotalUnusedLoc, len(reg.Result))
+
+	} else {
+		fmt.Fprintln(&out, "No unused exported identifiers found!")
+	}
+
+	if len(reg.Kept) > 0 {
+		kept := append([]Decl{}, reg.Kept...)
+		sort.Slice(kept, func(i, j int) bool {
+			return kept[i].Key() < kept[j].Key()
+		})
+		printSection(&out, "Exported Symbols Used By Consumers:", kept)
+	}
+
+	if len(reg.StaleBaseline) > 0 {
+		fmt.Fprintln(&out, "Stale Baseline Entries (no longer reported, can be removed):")
+		fmt.Fprintln(&out, "========================================================")
+		for _, entry := range reg.StaleBaseline {
+			fmt.Fprintf(&out, "%-6v %s.%s\n", entry.Kind, entry.Package, entry.Symbol)
+		}
+		fmt.Fprintln(&out, "========================================================")
+	}
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+func printSection(w io.Writer, title string, decls []Decl) {
+	if len(decls) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, "========================================================")
+
+	for _, decl := range decls {
+		if decl.Directive != "" {
+			fmt.Fprintf(w, "%-5v %s %s (%v)\n", decl.LineCount, decl.Key(), decl.Directive, decl.Pos.String())
+			continue
+		}
+		if decl.Implements != "" {
+			fmt.Fprintf(w, "%-5v %s implements %s (%v)\n", decl.LineCount, decl.Key(), decl.Implements, decl.Pos.String())
+			continue
+		}
+		fmt.Fprintf(w, "%-5v %s (%v)\n", decl.LineCount, decl.Key(), decl.Pos.String())
+		for _, file := range decl.TestFiles {
+			fmt.Fprintf(w, "      used in %s\n", file)
+		}
+		for _, mod := range decl.UsedBy {
+			fmt.Fprintf(w, "      used by %s\n", mod)
+		}
+	}
+
+	fmt.Fprintln(w, "========================================================")
+}
+
+// writeJSON writes the result as a JSONReport
+func (reg *Registry) writeJSON(w io.Writer) error {
+	report := JSONReport{
+		SchemaVersion: jsonSchemaVersion,
+		Summary:       reg.Summary(),
+		Findings:      reg.Issues(),
+		Kept:          issuesOf(reg.Kept),
+		StaleBaseline: reg.StaleBaseline,
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON: %v", err)
+	}
+
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
+
+func makeDecl(name string, start, end token.Pos, fset *token.FileSet) Decl {
+	pos := fset.Position(start)
+	endPos := fset.Position(end)
+	return Decl{
+		LineCount: endPos.Line - pos.Line + 1,
+		End:       endPos,
+		Pos:       pos,
+		Name:      name,
+	}
+}
+
+// Fix renames all unused exported symbols to unexported using gopls, writing
+// each rename and a summary to w
+func (reg *Registry) Fix(dryRun bool, w io.Writer) error {
+	// Check if gopls is installed
+	if _, err := exec.LookPath("gopls"); err != nil {
+		return fmt.Errorf("gopls not found. Install with: go install golang.org/x/tools/gopls@latest")
+	}
+
+	if len(reg.Result) == 0 {
+		fmt.Fprintln(w, "No unused exported symbols to fix!")
+		return nil
+	}
+
+	// Sort by file and line to process in order
+	sort.Slice(reg.Result, func(i, j int) bool {
+		if reg.Result[i].Pos.Filename != reg.Result[j].Pos.Filename {
+			return reg.Result[i].Pos.Filename < reg.Result[j].Pos.Filename
+		}
+		return reg.Result[i].Pos.Line < reg.Result[j].Pos.Line
+	})
+
+	successful := 0
+	skipped := 0
+	failed := 0
+
+	for _, decl := range reg.Result {
+		// methods satisfying an interface must keep their exported name
+		if decl.Category != CategoryUnused {
+			continue
+		}
+
+		newName := toUnexported(decl.Name)
+
+		// Skip if name doesn't change (shouldn't happen with exported symbols)
+		if newName == decl.Name {
+			if dryRun {
+				fmt.Fprintf(w, "⊘ Skip: %s (already unexported) at %s\n", decl.Name, decl.Pos.String())
+			}
+			skipped++
+			continue
+		}
+
+		if dryRun {
+			fmt.Fprintf(w, "→ Would rename: %s -> %s at %s\n", decl.Name, newName, decl.Pos.String())
+			successful++
+			continue
+		}
+
+		// Use gopls to rename
+		position := fmt.Sprintf("%s:%d:%d", decl.Pos.Filename, decl.Pos.Line, decl.Pos.Column)
+
+		cmd := exec.Command("gopls", "rename", "-w", position, newName)
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(w, "✗ Failed to rename %s: %v\n  %s\n", decl.Name, err, stderr.String())
+			failed++
+			continue
+		}
+
+		fmt.Fprintf(w, "✓ Renamed: %s -> %s\n", decl.Name, newName)
+		successful++
+	}
+
+	fmt.Fprintln(w)
+	if dryRun {
+		fmt.Fprintf(w, "Dry-run summary: %d would be renamed, %d skipped\n", successful, skipped)
+	} else {
+		fmt.Fprintf(w, "Summary: %d renamed, %d skipped, %d failed\n", successful, skipped, failed)
+		if failed > 0 {
+			return fmt.Errorf("some renames failed")
+		}
+	}
+
+	return nil
+}
+
+// toUnexported converts an exported identifier to unexported following Go naming conventions.
+// It properly handles acronyms and initialisms:
+// - HTTPServer -> httpServer (not hTTPServer)
+// - XMLParser -> xmlParser (not xMLParser)
+// - APIHandler -> apiHandler (not aPIHandler)
+// - ID -> id (not iD)
+// - URLPath -> urlPath
+// - HTTPs -> https (not httPs)
+func toUnexported(name string) string {
+	if name == "" {
+		return name
+	}
+
+	runes := []rune(name)
+	if len(runes) == 1 {
+		return string(unicode.ToLower(runes[0]))
+	}
+
+	// Find the end of the leading uppercase sequence
+	// This tells us where the first non-uppercase character is
+	firstLower := -1
+	for i := 0; i < len(runes); i++ {
+		if !unicode.IsUpper(runes[i]) {
+			firstLower = i
+			break
+		}
+	}
+
+	// If the whole name is uppercase, lowercase it all
+	// Example: "HTTP" -> "http", "ID" -> "id"
+	if firstLower == -1 {
+		for i := range runes {
+			runes[i] = unicode.ToLower(runes[i])
+		}
+		return string(runes)
+	}
+
+	// Now we have some uppercase letters followed by at least one non-uppercase
+	// Determine how many leading characters to lowercase
+	toLowerCount := firstLower
+
+	// If we have 2+ uppercase letters followed by an uppercase letter (new word),
+	// the last uppercase before the new word should not be lowercased
+	// Example: "HTTPServer" -> firstLower=4 (at 'e'), runes[4-1]='S' is uppercase
+	//          We want "http" + "Server", so lowercase first 4 chars (indices 0-3)
+	// Example: "HTTPs" -> firstLower=4 (at 's'), runes[4-1]='P' is uppercase
+	//          We want "https", so lowercase first 4 chars (indices 0-3)
+	// Example: "MyFunc" -> firstLower=1 (at 'y'), runes[0]='M'
+	//          We want "myFunc", so lowercase first 1 char
+
+	if firstLower > 1 && unicode.IsUpper(runes[firstLower-1]) {
+		// We have multiple uppercase letters before a non-uppercase
+		// Check if the non-uppercase character is followed by more content
+		// and if runes[firstLower-1] starts a new capitalized word
+
+		// Pattern: "HTTPServer" where 'S' at firstLower-1 is start of "Server"
+		// We want to keep 'S' capitalized, so only lowercase up to 'P'
+		if firstLower < len(runes)-1 {
+			// There are more characters after firstLower
+			// The uppercase at firstLower-1 likely starts a new word
+			toLowerCount = firstLower - 1
+		}
+		// else: Pattern like "HTTPs" - lowercase all the uppercase part
+	}
+
+	// Lowercase the determined portion
+	for i := 0; i < toLowerCount; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	return string(runes)
+}
+
+// findModule walks up from dir looking for a go.mod file and returns the
+// directory containing it, or an empty string when dir is not inside a
+// module. The module itself is read by addModule.
+func findModule(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// importPath returns the import path of the package in dir, inside of mod.
+// Outside of a module, when mod is nil, the slash-separated path relative to
+// the project root is used instead.
+func importPath(mod *Module, projectPath, dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	if mod != nil {
+		rel, err := filepath.Rel(mod.Dir, dir)
+		if err == nil && rel != "." {
+			return mod.Path + "/" + filepath.ToSlash(rel)
+		}
+		return mod.Path
+	}
+
+	if abs, err := filepath.Abs(projectPath); err == nil {
+		projectPath = abs
+	}
+
+	rel, err := filepath.Rel(projectPath, dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,2048 @@
+package dustat
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestFlow(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	t.Run("all-unused-found", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		fmt.Printf("reg.Result: %v\n", reg.Result)
+
+		if len(reg.Result) == 0 {
+			t.Fatal("expected unused declarations, but found none")
+		}
+
+		if err := resultIncludesName(reg.Result, "UnusedStruct"); err != nil {
+			t.Errorf("expected unused declaration 'UnusedStruct' not found: %v", err)
+		}
+	})
+
+	t.Run("igore-list-works", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		ignoreList := map[string]struct{}{
+			"UnusedButIgnoredStruct": {},
+		}
+
+		reg.WithIgnoreList(ignoreList)
+
+		if err := reg.Run(Output{lookupReporter(t, "text"), os.Stdout}); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		if err := resultIncludesName(reg.Result, "UnusedButIgnoredStruct"); err == nil {
+			t.Fatal("expected ignored declaration 'UnusedButIgnoredStruct' to be excluded, but it was found")
+		}
+
+		if len(reg.Result) != 2 {
+			t.Fatalf("expected 2 unused declarations, but found %d", len(reg.Result))
+		}
+	})
+}
+
+func TestPackageQualifiedDecls(t *testing.T) {
+	reg, err := NewRegistry("./testdata/multipkg")
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	for _, key := range []string{"example.com/multipkg/a.Config", "example.com/multipkg/b.Config"} {
+		if err := resultIncludesKey(reg.Result, key); err != nil {
+			t.Errorf("expected unused declaration %v: %v", key, err)
+		}
+	}
+
+	for _, key := range []string{"example.com/multipkg/a.New", "example.com/multipkg/b.New"} {
+		if _, ok := reg.Declarations[key]; !ok {
+			t.Errorf("expected declaration %v to be tracked", key)
+		}
+	}
+
+	if len(reg.Result) != 2 {
+		t.Fatalf("expected 2 unused declarations, but found %d", len(reg.Result))
+	}
+}
+
+func TestPackagePatterns(t *testing.T) {
+	tests := []struct {
+		name         string
+		patterns     []string
+		expected     []string
+		declarations int // the declarations of the selected packages
+	}{
+		{"all", []string{"./..."}, []string{"example.com/multipkg/a.Config", "example.com/multipkg/b.Config"}, 4},
+		{"directory", []string{"./a"}, []string{"example.com/multipkg/a.Config"}, 2},
+		{"root-package", []string{"."}, nil, 0},
+		{"import-path", []string{"example.com/multipkg/b"}, []string{"example.com/multipkg/b.Config"}, 2},
+		{"import-path-wildcard", []string{"example.com/multipkg/..."}, []string{"example.com/multipkg/a.Config", "example.com/multipkg/b.Config"}, 4},
+		{"several", []string{"./a/...", "example.com/multipkg/b"}, []string{"example.com/multipkg/a.Config", "example.com/multipkg/b.Config"}, 4},
+		{"no-match", []string{"example.com/other/..."}, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, err := NewRegistry("./testdata/multipkg")
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithPackages(tt.patterns...).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			// New is used by the main package, even when it is not selected
+			if len(reg.Result) != len(tt.expected) {
+				t.Fatalf("expected %v, found %v", tt.expected, reg.Result)
+			}
+
+			for _, key := range tt.expected {
+				if err := resultIncludesKey(reg.Result, key); err != nil {
+					t.Errorf("expected unused declaration %v: %v", key, err)
+				}
+			}
+
+			if summary := reg.Summary(); summary.Declarations != tt.declarations {
+				t.Errorf("expected %d declarations in the summary, found %d", tt.declarations, summary.Declarations)
+			}
+		})
+	}
+}
+
+func TestUsageFrom(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		typeCheck bool
+		packages  []string
+		usageFrom []string
+		expected  []string
+	}{
+		// tools is not part of the usage evidence once UsageFrom is set
+		{"report-and-usage", "./testdata/scope", false, []string{"./pkg/..."}, []string{"./cmd/...", "../sibling"}, []string{"Unused", "UsedByTool"}},
+		{"report-only", "./testdata/scope", false, []string{"./pkg/..."}, nil, []string{"Unused", "UsedBySibling"}},
+		{"usage-only", "./testdata/scope", false, nil, []string{"../sibling"}, []string{"Unused"}},
+		{"typecheck", "./testdata/scope/pkg", true, nil, []string{"../cmd/..."}, []string{"Unused", "UsedByTool", "UsedBySibling"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, err := NewRegistry(tt.path)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			reg.WithTypeCheck(tt.typeCheck).WithPackages(tt.packages...).WithUsageFrom(tt.usageFrom...)
+			if err := reg.Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			if len(reg.Result) != len(tt.expected) {
+				t.Fatalf("expected %v, found %v", tt.expected, reg.Result)
+			}
+
+			for _, name := range tt.expected {
+				if err := resultIncludesKey(reg.Result, "example.com/scope/pkg/lib."+name); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestModules(t *testing.T) {
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry("./testdata/modules")
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			if len(reg.Modules) != 4 {
+				t.Errorf("expected the 4 modules of the project, found %d", len(reg.Modules))
+			}
+
+			// other declares a Helper of its own, which does not keep lib.Helper alive
+			for _, key := range []string{"example.com/lib.Unused", "example.com/lib.Helper"} {
+				if err := resultIncludesKey(reg.Result, key); err != nil {
+					t.Errorf("expected unused declaration %v: %v", key, err)
+				}
+			}
+
+			// app is part of the same workspace as lib
+			for _, key := range []string{"example.com/lib.Used", "example.com/other.Helper"} {
+				if err := resultIncludesKey(reg.Result, key); err == nil {
+					t.Errorf("expected %v to be used", key)
+				}
+			}
+
+			found := false
+			for _, decl := range reg.Result {
+				if decl.Key() != "example.com/lib.Replaced" {
+					continue
+				}
+				found = true
+
+				if decl.Category != CategoryReplaceOnly {
+					t.Errorf("expected lib.Replaced to be %s, got %s", CategoryReplaceOnly, decl.Category)
+				}
+				if len(decl.UsedBy) != 1 || decl.UsedBy[0] != "example.com/plugin" {
+					t.Errorf("expected lib.Replaced to be used by example.com/plugin, got %v", decl.UsedBy)
+				}
+			}
+			if !found {
+				t.Error("expected lib.Replaced to be reported")
+			}
+
+			if reg.TotalUnusedLoc != 2 {
+				t.Errorf("expected 2 unused lines, found %d", reg.TotalUnusedLoc)
+			}
+		})
+	}
+
+	t.Run("workspace-module-outside-path", func(t *testing.T) {
+		reg, err := NewRegistry("./testdata/modules/lib")
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		// app is parsed for its uses, plugin is not part of the workspace
+		if err := resultIncludesKey(reg.Result, "example.com/lib.Used"); err == nil {
+			t.Error("expected example.com/lib.Used to be used by the app module")
+		}
+		if err := resultIncludesKey(reg.Result, "example.com/lib.Replaced"); err != nil {
+			t.Errorf("expected unused declaration example.com/lib.Replaced: %v", err)
+		}
+
+		for _, decl := range reg.Result {
+			if decl.Package != "example.com/lib" {
+				t.Errorf("expected only the declarations of lib, found %s", decl.Key())
+			}
+		}
+	})
+}
+
+func TestWorkspaceEnv(t *testing.T) {
+	tests := []struct {
+		goflags  string
+		expected []string
+	}{
+		{"", nil},
+		{"-mod=mod", []string{"GOFLAGS="}},
+		{"-mod=vendor -tags=integration --mod=readonly -trimpath", []string{"GOFLAGS=-tags=integration -trimpath"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goflags, func(t *testing.T) {
+			t.Setenv("GOFLAGS", tt.goflags)
+
+			if env := workspaceEnv(); !reflect.DeepEqual(env, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, env)
+			}
+		})
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	// the module path is read with golang.org/x/mod, quotes and comments included
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "// the module\nmodule \"example.com/quoted\" // trailing comment\n\ngo 1.18\n",
+		"pkg/pkg.go":     "package pkg\n\nfunc Unused() {}\n",
+		"cmd/app/app.go": "package main\n\nfunc main() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	if err := resultIncludesKey(reg.Result, "example.com/quoted/pkg.Unused"); err != nil {
+		t.Errorf("expected the import path from the module directive: %v", err)
+	}
+}
+
+func TestConsumers(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		consumers []string
+		unused    []string
+		kept      map[string]string // the consumers using each kept declaration
+	}{
+		{
+			// Helper is only used inside the library, consumer-b declares its
+			// own Helper and Version
+			name:      "imports",
+			path:      "./testdata/consumers/shared",
+			consumers: []string{"../consumer-a", "../consumer-b"},
+			unused:    []string{"Internal", "Helper", "Version", "Client.Retry"},
+			kept: map[string]string{
+				"Parse":     "example.com/consumer-a,example.com/consumer-b",
+				"Format":    "example.com/consumer-b",
+				"Client":    "example.com/consumer-a",
+				"Client.Do": "example.com/consumer-a",
+			},
+		},
+		{
+			// the declarations of the consumers are not reported
+			name:      "consumers-inside-path",
+			path:      "./testdata/consumers",
+			consumers: []string{"./consumer-a", "./consumer-b", "./consumer-c"},
+			unused:    []string{"Internal", "Helper", "Client.Retry"},
+			kept: map[string]string{
+				"Parse":     "example.com/consumer-a,example.com/consumer-b",
+				"Format":    "example.com/consumer-b",
+				"Client":    "example.com/consumer-a",
+				"Client.Do": "example.com/consumer-a",
+				"Version":   "example.com/consumer-c",
+			},
+		},
+		{
+			// consumer-c dot-imports the library, and has a Helper method
+			name:      "dot-import",
+			path:      "./testdata/consumers/shared",
+			consumers: []string{"../consumer-c"},
+			unused:    []string{"Parse", "Format", "Internal", "Helper", "Client", "Client.Do", "Client.Retry"},
+			kept:      map[string]string{"Version": "example.com/consumer-c"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, typeCheck := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/typecheck=%v", tt.name, typeCheck), func(t *testing.T) {
+				reg, err := NewRegistry(tt.path)
+				if err != nil {
+					t.Fatalf("failed to create registry: %v", err)
+				}
+
+				if err := reg.WithTypeCheck(typeCheck).WithConsumers(tt.consumers...).Run(); err != nil {
+					t.Fatalf("failed to run registry: %v", err)
+				}
+
+				for _, decl := range reg.Result {
+					if decl.Package != "example.com/shared" {
+						t.Errorf("expected only the declarations of the library, found %s", decl.Key())
+					}
+				}
+				if len(reg.Result) != len(tt.unused) {
+					t.Errorf("expected %d unused declarations, found %d", len(tt.unused), len(reg.Result))
+				}
+				if summary := reg.Summary(); summary.Declarations != 8 {
+					t.Errorf("expected the 8 declarations of the library in the summary, found %d", summary.Declarations)
+				}
+				for _, symbol := range tt.unused {
+					if err := resultIncludesKey(reg.Result, "example.com/shared."+symbol); err != nil {
+						t.Errorf("expected unused declaration %v: %v", symbol, err)
+					}
+				}
+
+				if len(reg.Kept) != len(tt.kept) {
+					t.Errorf("expected %d kept declarations, found %d", len(tt.kept), len(reg.Kept))
+				}
+				for _, decl := range reg.Kept {
+					usedBy, ok := tt.kept[decl.Symbol()]
+					if !ok {
+						t.Errorf("unexpected kept declaration %s", decl.Key())
+						continue
+					}
+					if got := strings.Join(decl.UsedBy, ","); got != usedBy {
+						t.Errorf("expected %s to be used by %s, got %s", decl.Key(), usedBy, got)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	t.Run("unresolved-import", func(t *testing.T) {
+		reg, err := NewRegistry("./testdata/typeerrors/badimport")
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		err = reg.WithTypeCheck(true).Run()
+		if err == nil || !strings.Contains(err.Error(), "could not import example.com/badimport/missing") {
+			t.Errorf("expected an error for the missing import, got %v", err)
+		}
+	})
+
+	t.Run("tolerated", func(t *testing.T) {
+		reg, err := NewRegistry("./testdata/typeerrors/mismatch")
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.WithTypeCheck(true).Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		if len(reg.TypeErrors) != 1 {
+			t.Errorf("expected 1 type error, got %v", reg.TypeErrors)
+		}
+
+		if err := resultIncludesKey(reg.Result, "example.com/mismatch.Count"); err == nil {
+			t.Error("expected Count to be used despite its type error")
+		}
+		if err := resultIncludesKey(reg.Result, "example.com/mismatch.Unused"); err != nil {
+			t.Errorf("expected unused declaration Unused: %v", err)
+		}
+	})
+}
+
+func TestTypeCheckedMode(t *testing.T) {
+	const typedProjectPath = "./testdata/typed"
+
+	t.Run("name-matching-hides-shadowed-symbols", func(t *testing.T) {
+		reg, err := NewRegistry(typedProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		if err := resultIncludesKey(reg.Result, "example.com/typed.Close"); err == nil {
+			t.Error("expected the local variable to count as a use of Close without type information")
+		}
+
+		// a selected field is never counted as a use of a package level name
+		if err := resultIncludesKey(reg.Result, "example.com/typed.A"); err != nil {
+			t.Errorf("expected unused declaration example.com/typed.A: %v", err)
+		}
+	})
+
+	t.Run("resolved-objects-only", func(t *testing.T) {
+		reg, err := NewRegistry(typedProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.WithTypeCheck(true).Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		for _, key := range []string{"example.com/typed.Close", "example.com/typed.A"} {
+			if err := resultIncludesKey(reg.Result, key); err != nil {
+				t.Errorf("expected unused declaration %v: %v", key, err)
+			}
+		}
+
+		if err := resultIncludesKey(reg.Result, "example.com/typed.Used"); err == nil {
+			t.Error("expected example.com/typed.Used to be used by the cmd package")
+		}
+	})
+
+	t.Run("same-result-on-test-project", func(t *testing.T) {
+		reg, err := NewRegistry("./testdata/project")
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.WithTypeCheck(true).Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		if len(reg.Result) != 3 {
+			t.Fatalf("expected 3 unused declarations, but found %d", len(reg.Result))
+		}
+	})
+}
+
+func TestMethods(t *testing.T) {
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry("./testdata/methods")
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			restart, ok := reg.Declarations["example.com/methods/server.Server.Restart"]
+			if !ok {
+				t.Fatal("expected method Server.Restart to be tracked with its receiver")
+			}
+			if restart.Kind != KindMethod || restart.Symbol() != "Server.Restart" {
+				t.Errorf("unexpected method declaration: kind=%v symbol=%v", restart.Kind, restart.Symbol())
+			}
+
+			for _, key := range []string{"example.com/methods/server.Server.Restart", "example.com/methods/server.Start"} {
+				if err := resultIncludesKey(reg.Result, key); err != nil {
+					t.Errorf("expected unused declaration %v: %v", key, err)
+				}
+			}
+
+			if len(reg.Result) != 2 {
+				t.Fatalf("expected 2 unused declarations, but found %d: %v", len(reg.Result), reg.Result)
+			}
+		})
+	}
+}
+
+func TestInterfaceMethods(t *testing.T) {
+	const ifacesProjectPath = "./testdata/ifaces"
+
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("hidden/typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry(ifacesProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			// Volume and the methods of Pool have the names of interface
+			// methods, with other parameters or results
+			expected := map[string]bool{
+				"example.com/ifaces/shapes.Square.Perimeter": true,
+				"example.com/ifaces/shapes.Square.Volume":    true,
+				"example.com/ifaces/shapes.Pool.Len":         true,
+				"example.com/ifaces/shapes.Pool.Value":       true,
+			}
+
+			if len(reg.Result) != len(expected) {
+				t.Fatalf("expected %d unused declarations, found %v", len(expected), reg.Result)
+			}
+			for _, decl := range reg.Result {
+				if !expected[decl.Key()] || decl.Category != CategoryUnused {
+					t.Errorf("expected %v not to be reported as %v", decl.Key(), decl.Category)
+				}
+			}
+		})
+
+		t.Run(fmt.Sprintf("listed/typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry(ifacesProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).WithInterfaceOnly(true).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			expected := map[string]string{
+				"example.com/ifaces/shapes.Square.String":   "fmt.Stringer",
+				"example.com/ifaces/shapes.NotFound.Error":  "error",
+				"example.com/ifaces/shapes.Doc.MarshalJSON": "encoding/json.Marshaler",
+			}
+
+			for _, decl := range reg.Result {
+				iface, ok := expected[decl.Key()]
+				if !ok {
+					if decl.Category == CategoryInterfaceOnly {
+						t.Errorf("expected %v not to be listed as interface-only", decl.Key())
+					}
+					continue
+				}
+				delete(expected, decl.Key())
+
+				if decl.Category != CategoryInterfaceOnly {
+					t.Errorf("expected %v to be %v, got %v", decl.Key(), CategoryInterfaceOnly, decl.Category)
+				}
+				// the interface is only known with type information
+				if !typeCheck {
+					iface = ""
+				}
+				if decl.Implements != iface {
+					t.Errorf("expected %v to implement %q, got %q", decl.Key(), iface, decl.Implements)
+				}
+			}
+
+			for key := range expected {
+				t.Errorf("expected %v to be listed as interface-only", key)
+			}
+
+			if reg.TotalUnusedLoc != 4 {
+				t.Errorf("expected interface-only methods not to count as unused lines, got %d", reg.TotalUnusedLoc)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry("./testdata/fields")
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).WithFields(true).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			expected := map[string]Category{
+				"example.com/fields/model.User.Age":      CategoryUnused,
+				"example.com/fields/model.Pair.Value":    CategoryUnused,
+				"example.com/fields/model.User.Password": CategoryTaggedField,
+			}
+
+			for _, decl := range reg.Result {
+				category, ok := expected[decl.Key()]
+				if !ok {
+					t.Errorf("unexpected unused declaration %v", decl.Key())
+					continue
+				}
+
+				if decl.Category != category {
+					t.Errorf("expected %v to be %v, got %v", decl.Key(), category, decl.Category)
+				}
+				delete(expected, decl.Key())
+			}
+
+			for key := range expected {
+				t.Errorf("expected unused field %v", key)
+			}
+		})
+	}
+
+	t.Run("opt-in", func(t *testing.T) {
+		reg, err := NewRegistry("./testdata/fields")
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		if _, ok := reg.Declarations["example.com/fields/model.User.Age"]; ok {
+			t.Error("expected fields not to be collected unless requested")
+		}
+	})
+}
+
+func TestTestModes(t *testing.T) {
+	const (
+		helper = "example.com/tests/lib.Helper"
+		dead   = "example.com/tests/lib.Dead"
+	)
+
+	tests := []struct {
+		mode     TestMode
+		expected map[string]Category
+	}{
+		{TestsCount, map[string]Category{dead: CategoryUnused}},
+		{TestsIgnore, map[string]Category{dead: CategoryUnused, helper: CategoryUnused}},
+		{TestsSeparate, map[string]Category{dead: CategoryUnused, helper: CategoryTestOnly}},
+	}
+
+	for _, tt := range tests {
+		for _, typeCheck := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%v/typecheck=%v", tt.mode, typeCheck), func(t *testing.T) {
+				reg, err := NewRegistry("./testdata/tests")
+				if err != nil {
+					t.Fatalf("failed to create registry: %v", err)
+				}
+
+				if err := reg.WithTypeCheck(typeCheck).WithTests(tt.mode).Run(); err != nil {
+					t.Fatalf("failed to run registry: %v", err)
+				}
+
+				if _, ok := reg.Declarations["example.com/tests/lib.Fixture"]; ok {
+					t.Error("expected declarations in test files not to be collected")
+				}
+
+				if len(reg.Result) != len(tt.expected) {
+					t.Fatalf("expected %d findings, found %v", len(tt.expected), reg.Result)
+				}
+
+				for _, decl := range reg.Result {
+					if decl.Category != tt.expected[decl.Key()] {
+						t.Errorf("expected %v to be %v, got %v", decl.Key(), tt.expected[decl.Key()], decl.Category)
+					}
+
+					if decl.Key() == helper && len(decl.TestFiles) != 2 {
+						t.Errorf("expected Helper to list the 2 test files using it, got %v", decl.TestFiles)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBuildConfigs(t *testing.T) {
+	const platformsProjectPath = "./testdata/platforms"
+
+	linux := BuildConfig{GOOS: "linux", GOARCH: "amd64"}
+	windows := BuildConfig{GOOS: "windows", GOARCH: "amd64"}
+	integration := BuildConfig{GOOS: "linux", GOARCH: "amd64", Tags: []string{"integration"}}
+
+	tests := []struct {
+		name     string
+		configs  []BuildConfig
+		expected []string
+	}{
+		{"linux", []BuildConfig{linux}, []string{"Common", "WindowsOnly", "TaggedOnly"}},
+		{"windows", []BuildConfig{windows}, []string{"Common", "LinuxOnly", "TaggedOnly", "WinAPI"}},
+		{"tags", []BuildConfig{integration}, []string{"Common", "WindowsOnly"}},
+		{"combined", []BuildConfig{linux, windows}, []string{"Common", "TaggedOnly", "WinAPI"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, err := NewRegistry(platformsProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithBuildConfigs(tt.configs...).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			if len(reg.Result) != len(tt.expected) {
+				t.Fatalf("expected %v, found %v", tt.expected, reg.Result)
+			}
+
+			for _, name := range tt.expected {
+				if err := resultIncludesName(reg.Result, name); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+
+	t.Run("typecheck-combined-tags", func(t *testing.T) {
+		reg, err := NewRegistry(platformsProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		configs := []BuildConfig{{}, {Tags: []string{"integration"}}}
+		if err := reg.WithTypeCheck(true).WithBuildConfigs(configs...).Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		if err := resultIncludesName(reg.Result, "TaggedOnly"); err == nil {
+			t.Error("expected TaggedOnly to be used in the integration configuration")
+		}
+
+		if err := resultIncludesName(reg.Result, "Common"); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestParseBuildConfigs(t *testing.T) {
+	configs, err := ParseBuildConfigs("linux/amd64, windows/arm64:integration+e2e", []string{"netgo"})
+	if err != nil {
+		t.Fatalf("failed to parse configurations: %v", err)
+	}
+
+	expected := []string{"linux/amd64:netgo", "windows/arm64:netgo+integration+e2e"}
+	if len(configs) != len(expected) {
+		t.Fatalf("expected %d configurations, got %v", len(expected), configs)
+	}
+
+	for i, config := range configs {
+		if config.String() != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], config)
+		}
+	}
+
+	if _, err := ParseBuildConfigs("linux", nil); err == nil {
+		t.Error("expected an error for a configuration without an architecture")
+	}
+}
+
+func TestGeneratedFiles(t *testing.T) {
+	const (
+		generatedProjectPath = "./testdata/generated"
+		getName              = "example.com/generated/api.Request.GetName"
+	)
+
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("skipped/typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry(generatedProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			// Normalize is kept alive by the generated code
+			if len(reg.Result) != 1 || reg.Result[0].Name != "Unused" {
+				t.Fatalf("expected only Unused to be reported, found %v", reg.Result)
+			}
+		})
+
+		t.Run(fmt.Sprintf("reported/typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry(generatedProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).WithGenerated(true).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			if err := resultIncludesKey(reg.Result, getName); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, decl := range reg.Result {
+				if decl.Key() == getName && decl.Category != CategoryGenerated {
+					t.Errorf("expected %v to be %v, got %v", getName, CategoryGenerated, decl.Category)
+				}
+			}
+
+			if reg.TotalUnusedLoc != 1 {
+				t.Errorf("expected generated code to be left out of the unused lines, got %d", reg.TotalUnusedLoc)
+			}
+		})
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	const suppressProjectPath = "./testdata/suppress"
+
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry(suppressProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).WithUnusedSuppressions(true).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			var reported, stale []string
+			for _, decl := range reg.Result {
+				if decl.Category == CategoryUnusedSuppression {
+					stale = append(stale, decl.Key())
+				} else {
+					reported = append(reported, decl.Name)
+				}
+			}
+			sort.Strings(reported)
+
+			// Kept, Dead, the const group, Single and the legacy package are suppressed
+			expected := []string{"NotDirective", "OtherLinter", "Plain"}
+			if !reflect.DeepEqual(reported, expected) {
+				t.Errorf("expected %v to be reported, got %v", expected, reported)
+			}
+
+			expectedStale := []string{"example.com/suppress/clean", "example.com/suppress/lib.Used"}
+			if !reflect.DeepEqual(stale, expectedStale) {
+				t.Errorf("expected unused suppressions %v, got %v", expectedStale, stale)
+			}
+
+			for _, decl := range reg.Result {
+				if decl.Category == CategoryUnusedSuppression && decl.Name == "Used" && decl.Directive != "//dustat:ignore no longer true" {
+					t.Errorf("expected the directive text to be kept, got %q", decl.Directive)
+				}
+			}
+		})
+	}
+
+	// the directives of the packages that are not reported are not checked
+	selections := []struct {
+		name     string
+		packages []string
+		stale    []string
+	}{
+		{"directory", []string{"./clean"}, []string{"example.com/suppress/clean"}},
+		{"import-path", []string{"example.com/suppress/legacy"}, nil},
+	}
+
+	for _, tt := range selections {
+		t.Run("selection/"+tt.name, func(t *testing.T) {
+			reg, err := NewRegistry(suppressProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithPackages(tt.packages...).WithUnusedSuppressions(true).Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			var stale []string
+			for _, decl := range reg.Result {
+				if decl.Category == CategoryUnusedSuppression {
+					stale = append(stale, decl.Key())
+				}
+			}
+			if !reflect.DeepEqual(stale, tt.stale) {
+				t.Errorf("expected unused suppressions %v, got %v", tt.stale, stale)
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	const configProjectPath = "./testdata/config"
+
+	t.Run("found-in-parent", func(t *testing.T) {
+		config, err := FindConfig(configProjectPath + "/core")
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if config == nil {
+			t.Fatal("expected the config of the parent directory to be found")
+		}
+
+		if !reflect.DeepEqual(config.Ignore, []string{"Kept"}) {
+			t.Errorf("unexpected ignore list %v", config.Ignore)
+		}
+		if !reflect.DeepEqual(config.IgnorePackages, []string{"example.com/config/legacy"}) {
+			t.Errorf("unexpected ignored packages %v", config.IgnorePackages)
+		}
+		if !reflect.DeepEqual(config.Exclude, []string{"tmp"}) {
+			t.Errorf("unexpected excluded directories %v", config.Exclude)
+		}
+		if config.Tests != "ignore" || config.Format != "json" {
+			t.Errorf("unexpected tests %q or format %q", config.Tests, config.Format)
+		}
+		if config.MaxFindings == nil || *config.MaxFindings != 1 {
+			t.Errorf("expected max findings of 1, got %v", config.MaxFindings)
+		}
+	})
+
+	t.Run("json-and-yaml-match", func(t *testing.T) {
+		dir := t.TempDir()
+		yml := filepath.Join(dir, ".dustat.yml")
+		jsn := filepath.Join(dir, ".dustat.json")
+
+		if err := os.WriteFile(yml, []byte("ignore:\n  - 'A'\n  - \"B # not a comment\"\nmax-findings: 0\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(jsn, []byte(`{"ignore": ["A", "B # not a comment"], "max-findings": 0}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		fromYAML, err := LoadConfig(yml)
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		fromJSON, err := LoadConfig(jsn)
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+
+		if !reflect.DeepEqual(fromYAML, fromJSON) {
+			t.Errorf("expected the same config, got %+v and %+v", fromYAML, fromJSON)
+		}
+	})
+
+	t.Run("inline-list-with-commas", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".dustat.yml")
+		if err := os.WriteFile(path, []byte("ignore: [\"~^A{2,3}$\", 'b,c', D]\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+
+		expected := []string{"~^A{2,3}$", "b,c", "D"}
+		if !reflect.DeepEqual(config.Ignore, expected) {
+			t.Errorf("expected %v, got %v", expected, config.Ignore)
+		}
+	})
+
+	t.Run("unknown-keys-rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".dustat.yml")
+		if err := os.WriteFile(path, []byte("ignored: [A]\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadConfig(path); err == nil {
+			t.Error("expected an error for an unknown key")
+		}
+	})
+
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("applied/typecheck=%v", typeCheck), func(t *testing.T) {
+			config, err := FindConfig(configProjectPath)
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+
+			reg, err := NewRegistry(configProjectPath)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			ignore := make(map[string]struct{})
+			for _, name := range config.Ignore {
+				ignore[name] = struct{}{}
+			}
+
+			reg.WithIgnoreList(ignore).
+				WithIgnorePackages(config.IgnorePackages...).
+				WithIgnorePaths(config.Paths(config.IgnorePaths)...).
+				WithExclude(config.Paths(config.Exclude)...).
+				WithTypeCheck(typeCheck)
+
+			if err := reg.Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			// the use of Dead in the excluded tmp directory is not counted
+			if len(reg.Result) != 1 || reg.Result[0].Key() != "example.com/config/core.Dead" {
+				t.Errorf("expected only core.Dead to be reported, found %v", reg.Result)
+			}
+		})
+	}
+}
+
+func TestIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		pattern  string
+		expected []string
+	}{
+		{"name", "./testdata/config", "Kept", []string{"Handler", "Old", "Scratch"}},
+		{"name-glob", "./testdata/config", "*d*", []string{"Kept", "Scratch"}},
+		{"file-location", "./testdata/config", "api/**:*Handler", []string{"Kept", "Old", "Scratch"}},
+		{"directory-location", "./testdata/config", "core:*", []string{"Handler", "Old", "Scratch"}},
+		{"package-location", "./testdata/config", "example.com/config/legacy:*", []string{"Handler", "Kept", "Scratch"}},
+		{"regexp", "./testdata/config", "~^(Old|Scratch)$", []string{"Handler", "Kept"}},
+		{"regexp-location", "./testdata/config", "~^tmp/:S*", []string{"Handler", "Kept", "Old"}},
+		// the colons of a group or a class do not end a regexp location
+		{"regexp-group", "./testdata/config", "~^(?:Old|Scratch)$", []string{"Handler", "Kept"}},
+		{"regexp-class", "./testdata/config", "~^[[:upper:]]ld$", []string{"Handler", "Kept", "Scratch"}},
+		{"regexp-location-and-symbol", "./testdata/config", "~^(?:tmp)/:~^(?:S)", []string{"Handler", "Kept", "Old"}},
+		{"receiver", "./testdata/methods", "Server.*", []string{"Start"}},
+		{"receiver-method-glob", "./testdata/methods", "*.Re*", []string{"Start"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, err := NewRegistry(tt.path)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			reg.WithIgnoreList(map[string]struct{}{tt.pattern: {}})
+			if err := reg.Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			var names []string
+			for _, decl := range reg.Result {
+				names = append(names, decl.Name)
+			}
+			sort.Strings(names)
+
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("expected %v to be reported with %q, got %v", tt.expected, tt.pattern, names)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, pattern := range []string{"~(", "api:", "[a"} {
+			reg, err := NewRegistry("./testdata/config")
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			reg.WithIgnoreList(map[string]struct{}{pattern: {}})
+			if err := reg.Run(); err == nil {
+				t.Errorf("expected an error for the pattern %q", pattern)
+			}
+		}
+	})
+}
+
+func TestBaseline(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	reg, err := NewRegistry(testProjectPath)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	// record UnusedStruct and a symbol that has since been removed
+	var recorded []Decl
+	for _, decl := range reg.Result {
+		if decl.Name == "UnusedStruct" {
+			recorded = append(recorded, decl)
+		}
+	}
+	removed := Decl{Name: "Removed", Package: "github.com/tompston/dustat/pkg/dustat/testdata/project", Kind: KindFunc}
+	recorded = append(recorded, removed)
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := NewBaseline(recorded).Write(path); err != nil {
+		t.Fatalf("failed to write baseline: %v", err)
+	}
+
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("failed to load baseline: %v", err)
+	}
+
+	reg, err = NewRegistry(testProjectPath)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	if err := reg.WithBaseline(baseline).Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	if err := resultIncludesName(reg.Result, "UnusedStruct"); err == nil {
+		t.Error("expected UnusedStruct to be hidden by the baseline")
+	}
+	if err := resultIncludesName(reg.Result, "MY_CONS"); err != nil {
+		t.Error(err)
+	}
+
+	if len(reg.StaleBaseline) != 1 || reg.StaleBaseline[0].Fingerprint != removed.Fingerprint() {
+		t.Errorf("expected only Removed to be stale, got %v", reg.StaleBaseline)
+	}
+
+	total := 0
+	for _, decl := range reg.Result {
+		total += decl.LineCount
+	}
+	if reg.TotalUnusedLoc != total {
+		t.Errorf("expected %d unused lines without the baseline, got %d", total, reg.TotalUnusedLoc)
+	}
+
+	t.Run("fingerprint-ignores-position", func(t *testing.T) {
+		moved := recorded[0]
+		moved.Pos.Line += 10
+		moved.End.Line += 10
+		if moved.Fingerprint() != recorded[0].Fingerprint() {
+			t.Error("expected the fingerprint to stay the same when the declaration moves")
+		}
+
+		method := recorded[0]
+		method.Kind = KindMethod
+		if method.Fingerprint() == recorded[0].Fingerprint() {
+			t.Error("expected the kind to be part of the fingerprint")
+		}
+	})
+}
+
+func TestSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	write("go.mod", "module example.com/since\n\ngo 1.18\n")
+	write("lib/lib.go", "package lib\n\nfunc Old() {}\n\nfunc Touched() {\n}\n")
+	run("init", "-q")
+	run("add", "-A")
+	run("commit", "-q", "-m", "initial")
+
+	write("lib/lib.go", "package lib\n\nfunc Old() {}\n\nfunc Touched() {\n\tprintln()\n}\n\nfunc Added() {}\n")
+	write("lib/new.go", "package lib\n\nfunc Untracked() {}\n")
+
+	for _, typeCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typecheck=%v", typeCheck), func(t *testing.T) {
+			reg, err := NewRegistry(dir)
+			if err != nil {
+				t.Fatalf("failed to create registry: %v", err)
+			}
+
+			if err := reg.WithTypeCheck(typeCheck).WithSince("HEAD").Run(); err != nil {
+				t.Fatalf("failed to run registry: %v", err)
+			}
+
+			var names []string
+			for _, decl := range reg.Result {
+				names = append(names, decl.Name)
+			}
+			sort.Strings(names)
+
+			// Old is unused as well, but was not changed
+			expected := []string{"Added", "Touched", "Untracked"}
+			if !reflect.DeepEqual(names, expected) {
+				t.Errorf("expected %v to be reported, got %v", expected, names)
+			}
+		})
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -3 +3 @@ func A() {
+-	return 1
++	return 2
+@@ -10,2 +10,0 @@ func B() {
+-	x := 1
+-	_ = x
+@@ -20,0 +19,3 @@
++func C() {
++}
++
+diff --git a/gone.go b/gone.go
+--- a/gone.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package gone
+`
+
+	changed, err := parseDiff("/repo", []byte(diff))
+	if err != nil {
+		t.Fatalf("failed to parse diff: %v", err)
+	}
+
+	expected := map[string][]lineRange{
+		filepath.Join("/repo", "a.go"): {{3, 3}, {10, 11}, {19, 21}},
+	}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v, got %v", expected, changed)
+	}
+}
+
+func TestSARIFOutput(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	reg, err := NewRegistry(testProjectPath)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	output := reportOutput(t, reg, "sarif")
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(output), &log); err != nil {
+		t.Fatalf("failed to parse SARIF output: %v\nOutput: %s", err, output)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected a single SARIF 2.1.0 run, got version %q with %d runs", log.Version, len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if len(run.Results) != len(reg.Result) {
+		t.Fatalf("expected %d results, got %d", len(reg.Result), len(run.Results))
+	}
+
+	rules := make(map[string]bool)
+	for _, rule := range run.Tool.Driver.Rules {
+		rules[rule.ID] = true
+	}
+
+	found := false
+	for _, result := range run.Results {
+		if !rules[result.RuleID] || run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+			t.Errorf("result refers to an unknown rule %q", result.RuleID)
+		}
+		if result.PartialFingerprints["dustat/v1"] == "" {
+			t.Errorf("expected a partial fingerprint for %q", result.Message.Text)
+		}
+
+		location := result.Locations[0].PhysicalLocation
+		if location.Region.EndLine < location.Region.StartLine {
+			t.Errorf("invalid region %+v", location.Region)
+		}
+
+		if result.Message.Text == "exported type UnusedStruct is never used" {
+			found = true
+			if location.ArtifactLocation.URI != "project.go" || location.ArtifactLocation.URIBaseID != sarifRootID {
+				t.Errorf("expected project.go relative to the project root, got %+v", location.ArtifactLocation)
+			}
+			if location.Region.StartLine != 12 || location.Region.EndLine != 14 || location.Region.StartColumn != 6 {
+				t.Errorf("unexpected region for UnusedStruct %+v", location.Region)
+			}
+		}
+	}
+
+	if !found {
+		t.Error("expected UnusedStruct in the SARIF results")
+	}
+}
+
+func TestXMLOutput(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	reg, err := NewRegistry(testProjectPath)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	t.Run("checkstyle", func(t *testing.T) {
+		output := reportOutput(t, reg, "checkstyle")
+
+		var report checkstyleReport
+		if err := xml.Unmarshal([]byte(output), &report); err != nil {
+			t.Fatalf("failed to parse Checkstyle output: %v\nOutput: %s", err, output)
+		}
+
+		if len(report.Files) != 1 || len(report.Files[0].Errors) != len(reg.Result) {
+			t.Fatalf("expected %d errors in a single file, got %+v", len(reg.Result), report.Files)
+		}
+
+		first := report.Files[0].Errors[0]
+		if first.Line != 12 || first.Column != 6 || first.Severity != "warning" || first.Source != "dustat.unused.type" {
+			t.Errorf("unexpected error for UnusedStruct %+v", first)
+		}
+		if !strings.Contains(first.Message, "lines 12-14") {
+			t.Errorf("expected the line span in the message, got %q", first.Message)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		output := reportOutput(t, reg, "junit")
+
+		var report junitTestSuites
+		if err := xml.Unmarshal([]byte(output), &report); err != nil {
+			t.Fatalf("failed to parse JUnit output: %v\nOutput: %s", err, output)
+		}
+
+		if report.Tests != len(reg.Result) || report.Failures != len(reg.Result) || len(report.Suites) != 1 {
+			t.Fatalf("expected %d failures in a single suite, got %+v", len(reg.Result), report)
+		}
+
+		first := report.Suites[0].Cases[0]
+		if first.Name != "github.com/tompston/dustat/pkg/dustat/testdata/project.UnusedStruct" || first.Failure.Type != string(CategoryUnused) {
+			t.Errorf("unexpected test case %+v", first)
+		}
+		if !strings.Contains(first.Failure.Text, "kind: type") || !strings.Contains(first.Failure.Text, "project.go:12:6-14:2") {
+			t.Errorf("expected the kind and span in the failure, got %q", first.Failure.Text)
+		}
+	})
+}
+
+func TestGitHubOutput(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "service")
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "api.go"), []byte("package service\n\nfunc Unused() {\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := NewRegistry(project)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	output := reportOutput(t, reg, "github")
+
+	// the path is relative to the repository, not to the analyzed directory
+	expected := "::warning file=service/api.go,line=3,endLine=4,col=6,endColumn=2,title=dustat unused::exported func Unused is never used\n"
+	if output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+
+	if got := escapeProperty("a,b:c%\n"); got != "a%2Cb%3Ac%25%0A" {
+		t.Errorf("unexpected escaped property %q", got)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"internal/api", "internal/api", true},
+		{"internal/*", "internal/api", true},
+		{"internal/*", "internal/api/v1", false},
+		{"internal/**", "internal/api/v1", true},
+		{"internal/**", "internal", true},
+		{"**/api", "internal/api", true},
+		{"**/*.pb.go", "api/v1/user.pb.go", true},
+		{"**/*.pb.go", "api/v1/user.go", false},
+		{"github.com/org/**/legacy", "github.com/org/repo/legacy", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func resultIncludesKey(result []Decl, key string) error {
+	for _, decl := range result {
+		if decl.Key() == key {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("expected key %v not found in result", key)
+}
+
+func resultIncludesName(result []Decl, name string) error {
+	for _, decl := range result {
+		if decl.Name == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("expected name %v not found in result", name)
+}
+
+func TestFixDryRun(t *testing.T) {
+	if _, err := exec.LookPath("gopls"); err != nil {
+		t.Skip("gopls not found")
+	}
+
+	reg, err := NewRegistry("./testdata/multipkg")
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if err := reg.Run(); err != nil {
+		t.Fatalf("failed to run registry: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := reg.Fix(true, &out); err != nil {
+		t.Fatalf("failed to fix: %v", err)
+	}
+
+	for _, want := range []string{"Would rename: Config -> config", "Dry-run summary: 2 would be renamed, 0 skipped"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestToUnexported(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		// Basic cases
+		{"simple", "MyFunc", "myFunc"},
+		{"single char", "A", "a"},
+		{"empty", "", ""},
+		{"already lowercase", "myFunc", "myFunc"},
+
+		// Common acronyms with words
+		{"HTTP with word", "HTTPServer", "httpServer"},
+		{"API with word", "APIHandler", "apiHandler"},
+		{"XML with word", "XMLParser", "xmlParser"},
+		{"URL with word", "URLPath", "urlPath"},
+		{"JSON with word", "JSONEncoder", "jsonEncoder"},
+		{"SQL with word", "SQLDatabase", "sqlDatabase"},
+		{"TCP with word", "TCPConnection", "tcpConnection"},
+		{"UDP with word", "UDPSocket", "udpSocket"},
+		{"TLS with word", "TLSConfig", "tlsConfig"},
+		{"DNS with word", "DNSResolver", "dnsResolver"},
+
+		// ID cases (special case mentioned in requirements)
+		{"ID alone", "ID", "id"},
+		{"ID with prefix", "UserID", "userID"},
+		{"ID with suffix", "IDGenerator", "idGenerator"},
+		{"AppID", "AppID", "appID"},
+		{"RequestID", "RequestID", "requestID"},
+
+		// Multiple acronyms
+		{"HTTP + API", "HTTPAPI", "httpapi"},
+		{"XML + HTTP", "XMLHTTP", "xmlhttp"},
+		{"HTTP + URL", "HTTPURL", "httpurl"},
+		{"API + URL", "APIURL", "apiurl"},
+
+		// Multiple acronyms with word
+		{"XML + HTTP + Request", "XMLHTTPRequest", "xmlhttpRequest"},
+		{"API + URL + Path", "APIURLPath", "apiurlPath"},
+
+		// All caps (acronyms only)
+		{"HTTP only", "HTTP", "http"},
+		{"API only", "API", "api"},
+		{"XML only", "XML", "xml"},
+		{"URL only", "URL", "url"},
+		{"JSON only", "JSON", "json"},
+
+		// Edge cases
+		{"two letters", "IO", "io"},
+		{"acronym + single letter", "HTTPs", "https"},
+		{"single uppercase + word", "AStruct", "aStruct"},
+
+		// Real-world examples
+		{"HTTPClient", "HTTPClient", "httpClient"},
+		{"HTTPRequest", "HTTPRequest", "httpRequest"},
+		{"HTTPResponse", "HTTPResponse", "httpResponse"},
+		{"XMLDecoder", "XMLDecoder", "xmlDecoder"},
+		{"JSONMarshaler", "JSONMarshaler", "jsonMarshaler"},
+		{"URLEncoder", "URLEncoder", "urlEncoder"},
+		{"APIClient", "APIClient", "apiClient"},
+		{"APIKey", "APIKey", "apiKey"},
+		{"DBConnection", "DBConnection", "dbConnection"},
+		{"OSVersion", "OSVersion", "osVersion"},
+
+		// Should NOT use mixed case
+		{"avoid Url", "Url", "url"},    // Should be URL or url, not Url
+		{"avoid Http", "Http", "http"}, // Should be HTTP or http, not Http
+		{"avoid Id", "Id", "id"},       // Should be ID or id, not Id
+		{"avoid Api", "Api", "api"},    // Should be API or api, not Api
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := toUnexported(tt.input)
+			if result != tt.expected {
+				t.Errorf("toUnexported(%q) = %q; want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToUnexportedProperties(t *testing.T) {
+	t.Run("result should start with lowercase", func(t *testing.T) {
+		inputs := []string{"MyFunc", "HTTPServer", "ID", "APIKey", "XMLParser"}
+		for _, input := range inputs {
+			if input == "" {
+				continue
+			}
+			result := toUnexported(input)
+			if result != "" && !unicode.IsLower(rune(result[0])) {
+				t.Errorf("toUnexported(%q) = %q; first character should be lowercase", input, result)
+			}
+		}
+	})
+
+	t.Run("should not create mixed-case acronyms", func(t *testing.T) {
+		// These are invalid patterns we want to avoid
+		badPatterns := []struct {
+			input    string
+			badStart string
+		}{
+			{"HTTPServer", "hTTP"}, // Should be "http", not "hTTP"
+			{"APIHandler", "aPI"},  // Should be "api", not "aPI"
+			{"XMLParser", "xML"},   // Should be "xml", not "xML"
+			{"ID", "iD"},           // Should be "id", not "iD"
+		}
+
+		for _, bp := range badPatterns {
+			result := toUnexported(bp.input)
+			if len(result) >= len(bp.badStart) && result[:len(bp.badStart)] == bp.badStart {
+				t.Errorf("toUnexported(%q) = %q; should not start with %q (mixed-case acronym)",
+					bp.input, result, bp.badStart)
+			}
+		}
+	})
+}
+
+// captureJSONOutput captures stdout and returns the JSON output as a string
+func captureJSONOutput(t *testing.T, fn func()) string {
+	t.Helper()
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	fn()
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close pipe writer: %v", err)
+	}
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		t.Fatalf("failed to copy output: %v", err)
+	}
+
+	return buf.String()
+}
+
+func lookupReporter(t *testing.T, format string) Reporter {
+	t.Helper()
+
+	reporter, err := LookupReporter(format)
+	if err != nil {
+		t.Fatalf("failed to look up reporter: %v", err)
+	}
+	return reporter
+}
+
+// reportOutput returns the result written in the format
+func reportOutput(t *testing.T, reg *Registry, format string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := reg.Report(lookupReporter(t, format), &buf); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	return buf.String()
+}
+
+func TestJSONOutput(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	t.Run("json-output-is-valid", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		output := reportOutput(t, reg, "json")
+
+		// Verify output is valid JSON
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("failed to parse JSON output: %v\nOutput: %s", err, output)
+		}
+
+		if result.SchemaVersion != jsonSchemaVersion {
+			t.Errorf("expected schema version %d, got %d", jsonSchemaVersion, result.SchemaVersion)
+		}
+
+		if len(result.Findings) == 0 {
+			t.Fatal("expected at least one finding in JSON output")
+		}
+	})
+
+	t.Run("json-output-contains-expected-fields", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		output := reportOutput(t, reg, "json")
+
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+
+		// Verify structure
+		if len(result.Findings) == 0 {
+			t.Fatal("expected at least one finding in result")
+		}
+
+		for _, issue := range result.Findings {
+			if issue.Location.File == "" {
+				t.Errorf("expected file path to be non-empty for symbol %s", issue.Symbol)
+			}
+			if issue.Symbol == "" || issue.Package == "" || issue.Kind == "" || issue.Fingerprint == "" {
+				t.Errorf("expected symbol, package, kind and fingerprint to be non-empty, got %+v", issue)
+			}
+			if issue.Location.Line <= 0 || issue.Location.Column <= 0 {
+				t.Errorf("expected position to be positive, got %+v for symbol %s", issue.Location, issue.Symbol)
+			}
+			if issue.Location.EndLine < issue.Location.Line {
+				t.Errorf("expected end line after the start, got %+v for symbol %s", issue.Location, issue.Symbol)
+			}
+		}
+	})
+
+	t.Run("json-output-includes-summary", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		output := reportOutput(t, reg, "json")
+
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+
+		expected := Summary{
+			Declarations:     len(reg.Declarations),
+			Findings:         3,
+			Unused:           3,
+			TotalUnusedLines: reg.TotalUnusedLoc,
+		}
+		if result.Summary != expected {
+			t.Errorf("expected summary %+v, got %+v", expected, result.Summary)
+		}
+	})
+
+	t.Run("json-output-includes-expected-symbols", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		output := reportOutput(t, reg, "json")
+
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+
+		// Check that UnusedStruct is in the results
+		foundUnusedStruct := false
+		for _, issue := range result.Findings {
+			if issue.Symbol == "UnusedStruct" {
+				foundUnusedStruct = true
+
+				expected := Location{File: issue.Location.File, Line: 12, Column: 6, EndLine: 14, EndColumn: 2, LineCount: 3}
+				if issue.Location != expected {
+					t.Errorf("expected UnusedStruct at %+v, got %+v", expected, issue.Location)
+				}
+				if issue.Kind != KindType {
+					t.Errorf("expected UnusedStruct to be a type, got %v", issue.Kind)
+				}
+				break
+			}
+		}
+
+		if !foundUnusedStruct {
+			t.Error("expected to find UnusedStruct in JSON output")
+		}
+	})
+
+	t.Run("json-output-respects-ignore-list", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		ignoreList := map[string]struct{}{
+			"UnusedButIgnoredStruct": {},
+		}
+		reg.WithIgnoreList(ignoreList)
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		output := reportOutput(t, reg, "json")
+
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+
+		// Verify UnusedButIgnoredStruct is not in results
+		for _, issue := range result.Findings {
+			if issue.Symbol == "UnusedButIgnoredStruct" {
+				t.Error("expected UnusedButIgnoredStruct to be ignored, but found in JSON output")
+			}
+		}
+	})
+
+	t.Run("json-output-empty-when-all-ignored", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		ignoreList := map[string]struct{}{
+			"UnusedStruct":           {},
+			"UnusedButIgnoredStruct": {},
+			"MY_CONS":                {},
+		}
+		reg.WithIgnoreList(ignoreList)
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		output := reportOutput(t, reg, "json")
+
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+
+		if len(result.Findings) != 0 {
+			t.Errorf("expected no findings, got %d", len(result.Findings))
+		}
+
+		// Verify the findings are an empty array rather than null
+		if !strings.Contains(output, `"findings": []`) {
+			t.Errorf("expected output to contain an empty findings array, got: %s", output)
+		}
+	})
+
+	t.Run("json-findings-sorted-by-file-and-line", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		output := reportOutput(t, reg, "json")
+
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+
+		// Verify findings are sorted by file, then by line number
+		for i := 1; i < len(result.Findings); i++ {
+			prev, cur := result.Findings[i-1].Location, result.Findings[i].Location
+			if cur.File < prev.File || (cur.File == prev.File && cur.Line < prev.Line) {
+				t.Errorf("findings not sorted: %s:%d came before %s:%d", prev.File, prev.Line, cur.File, cur.Line)
+			}
+		}
+	})
+}
+
+func TestRunProducesStdoutOutput(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	t.Run("text-output-mode", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		output := captureJSONOutput(t, func() {
+			if err := reg.Run(Output{lookupReporter(t, "text"), os.Stdout}); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		})
+
+		if output == "" {
+			t.Fatal("expected text output to stdout, got empty string")
+		}
+
+		if !bytes.Contains([]byte(output), []byte("Unused Exported Symbols")) {
+			t.Errorf("expected output to contain 'Unused Exported Symbols', got: %s", output)
+		}
+	})
+
+	t.Run("json-output-mode", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		output := captureJSONOutput(t, func() {
+			if err := reg.Run(Output{lookupReporter(t, "json"), os.Stdout}); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		})
+
+		if output == "" {
+			t.Fatal("expected JSON output to stdout, got empty string")
+		}
+
+		var result JSONReport
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("expected valid JSON output, got parse error: %v\nOutput: %s", err, output)
+		}
+	})
+}
+
+func TestReporters(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	t.Run("multiple-outputs", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		var text, sarif bytes.Buffer
+		err = reg.Run(
+			Output{lookupReporter(t, "text"), &text},
+			Output{lookupReporter(t, "sarif"), &sarif},
+		)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if !strings.Contains(text.String(), "Unused Exported Symbols") {
+			t.Errorf("expected text output, got: %s", text.String())
+		}
+
+		var log sarifLog
+		if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+			t.Fatalf("expected a SARIF log, got parse error: %v", err)
+		}
+		if len(log.Runs) != 1 || len(log.Runs[0].Results) != len(reg.Result) {
+			t.Errorf("expected %d SARIF results, got %+v", len(reg.Result), log.Runs)
+		}
+	})
+
+	t.Run("custom-reporter", func(t *testing.T) {
+		RegisterReporter("count", ReporterFunc(func(reg *Registry, w io.Writer) error {
+			_, err := fmt.Fprintf(w, "%d\n", len(reg.Result))
+			return err
+		}))
+		defer delete(reporters, "count")
+
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := reg.Run(Output{lookupReporter(t, "count"), &buf}); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if want := fmt.Sprintf("%d\n", len(reg.Result)); buf.String() != want {
+			t.Errorf("expected %q, got %q", want, buf.String())
+		}
+	})
+}
+
+func TestLibraryAPI(t *testing.T) {
+	const testProjectPath = "./testdata/project"
+
+	t.Run("filter", func(t *testing.T) {
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		reg.WithFilter(func(decl Decl) bool { return decl.Kind != KindConst })
+		if err := reg.Run(); err != nil {
+			t.Fatalf("failed to run registry: %v", err)
+		}
+
+		if err := resultIncludesName(reg.Result, "MY_CONS"); err == nil {
+			t.Errorf("expected MY_CONS to be filtered out")
+		}
+		if err := resultIncludesName(reg.Result, "UnusedStruct"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("own-file-set", func(t *testing.T) {
+		const src = `package api
+
+type Used struct{}
+
+type Unused struct{}
+
+var _ = Used{}
+`
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "api.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("failed to parse file: %v", err)
+		}
+
+		reg, err := NewRegistry(testProjectPath)
+		if err != nil {
+			t.Fatalf("failed to create registry: %v", err)
+		}
+
+		reg.WithFileSet(fset).AddFile(file, "api.go", "example.com/api")
+		if err := reg.AccumulateResult(); err != nil {
+			t.Fatalf("failed to accumulate results: %v", err)
+		}
+
+		if len(reg.Result) != 1 || reg.Result[0].Key() != "example.com/api.Unused" {
+			t.Fatalf("expected only example.com/api.Unused, got %+v", reg.Result)
+		}
+
+		issues := reg.Issues()
+		want := Location{File: "api.go", Line: 5, Column: 6, EndLine: 5, EndColumn: 21, LineCount: 1}
+		if len(issues) != 1 || issues[0].Location != want {
+			t.Errorf("expected an issue at %+v, got %+v", want, issues)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package dustat
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeGitHub writes the result as GitHub Actions workflow commands, which
+// show up as annotations on the lines of a pull request.
+func (reg *Registry) writeGitHub(w io.Writer) error {
+	root := repoRoot(reg.root())
+
+	for _, file := range groupByFile(reg.Result) {
+		name := file.name
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+		if rel, err := filepath.Rel(root, name); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+
+		for _, decl := range file.decls {
+			command := "warning"
+			if decl.severity() != "warning" {
+				command = "notice"
+			}
+
+			_, err := fmt.Fprintf(w, "::%s file=%s,line=%d,endLine=%d,col=%d,endColumn=%d,title=%s::%s\n",
+				command,
+				escapeProperty(filepath.ToSlash(name)),
+				decl.Pos.Line, decl.End.Line, decl.Pos.Column, decl.End.Column,
+				escapeProperty("dustat "+string(decl.Category)),
+				escapeData(decl.Message()),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// repoRoot returns the closest directory above dir containing .git, which is
+// the directory GitHub resolves annotation paths against. dir is returned
+// when it is not inside a repository.
+func repoRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes the value of a workflow command property
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
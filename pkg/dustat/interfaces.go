@@ -0,0 +1,231 @@
+package dustat
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// stdlibInterfaceMethods maps the methods of commonly implemented standard
+// library interfaces to an interface declaring them, along with the number of
+// their parameters and results. Without type information an exported method
+// with one of these names and as many parameters and results is assumed to
+// implement some interface, but which one is not known.
+var stdlibInterfaceMethods = map[string]stdlibMethod{
+	"Error":           {"error", funcShape{0, 1}},
+	"String":          {"fmt.Stringer", funcShape{0, 1}},
+	"GoString":        {"fmt.GoStringer", funcShape{0, 1}},
+	"Format":          {"fmt.Formatter", funcShape{2, 0}},
+	"MarshalJSON":     {"encoding/json.Marshaler", funcShape{0, 2}},
+	"UnmarshalJSON":   {"encoding/json.Unmarshaler", funcShape{1, 1}},
+	"MarshalXML":      {"encoding/xml.Marshaler", funcShape{2, 1}},
+	"UnmarshalXML":    {"encoding/xml.Unmarshaler", funcShape{2, 1}},
+	"MarshalText":     {"encoding.TextMarshaler", funcShape{0, 2}},
+	"UnmarshalText":   {"encoding.TextUnmarshaler", funcShape{1, 1}},
+	"MarshalBinary":   {"encoding.BinaryMarshaler", funcShape{0, 2}},
+	"UnmarshalBinary": {"encoding.BinaryUnmarshaler", funcShape{1, 1}},
+	"GobEncode":       {"encoding/gob.GobEncoder", funcShape{0, 2}},
+	"GobDecode":       {"encoding/gob.GobDecoder", funcShape{1, 1}},
+	"ServeHTTP":       {"net/http.Handler", funcShape{2, 0}},
+	"Read":            {"io.Reader", funcShape{1, 2}},
+	"Write":           {"io.Writer", funcShape{1, 2}},
+	"Close":           {"io.Closer", funcShape{0, 1}},
+	"Seek":            {"io.Seeker", funcShape{2, 2}},
+	"ReadFrom":        {"io.ReaderFrom", funcShape{1, 2}},
+	"WriteTo":         {"io.WriterTo", funcShape{1, 2}},
+	"ReadAt":          {"io.ReaderAt", funcShape{2, 2}},
+	"WriteAt":         {"io.WriterAt", funcShape{2, 2}},
+	"Len":             {"sort.Interface", funcShape{0, 1}},
+	"Less":            {"sort.Interface", funcShape{2, 1}},
+	"Swap":            {"sort.Interface", funcShape{2, 0}},
+	"Push":            {"container/heap.Interface", funcShape{1, 0}},
+	"Pop":             {"container/heap.Interface", funcShape{0, 1}},
+	"Scan":            {"database/sql.Scanner", funcShape{1, 1}},
+	"Value":           {"database/sql/driver.Valuer", funcShape{0, 2}},
+	"Unwrap":          {"interface{ Unwrap() error }", funcShape{0, 1}},
+	"Is":              {"interface{ Is(error) bool }", funcShape{1, 1}},
+	"As":              {"interface{ As(any) bool }", funcShape{1, 1}},
+}
+
+type stdlibMethod struct {
+	iface string
+	shape funcShape
+}
+
+// funcShape is the number of parameters and results of a function, all that
+// is compared when matching a method with an interface without types.
+type funcShape struct {
+	params, results int
+}
+
+func shapeOf(fn *ast.FuncType) funcShape {
+	return funcShape{fn.Params.NumFields(), fn.Results.NumFields()}
+}
+
+// stdlibInterfacePackages are loaded in the type-checked mode even when the
+// project does not import them, so that their interfaces are known.
+var stdlibInterfacePackages = []string{
+	"container/heap",
+	"context",
+	"database/sql",
+	"database/sql/driver",
+	"encoding",
+	"encoding/gob",
+	"encoding/json",
+	"encoding/xml",
+	"flag",
+	"fmt",
+	"io",
+	"net/http",
+	"sort",
+}
+
+// interfaceKey is the UsageCount key for the methods declared by interface
+// types in the default mode, by name and shape.
+func interfaceKey(name string, shape funcShape) string {
+	return fmt.Sprintf("interface.%s/%d/%d", name, shape.params, shape.results)
+}
+
+// implements reports whether decl is a method that satisfies an interface,
+// along with the name of the interface when it is known. Without type
+// information a method is assumed to satisfy an interface when one of the
+// standard library interfaces or an interface of the project declares a
+// method with its name and shape, and the interface is not named.
+func (reg *Registry) implements(decl Decl) (string, bool) {
+	if decl.Kind != KindMethod {
+		return "", false
+	}
+
+	if reg.TypeCheck {
+		iface, ok := reg.InterfaceMethods[decl.Key()]
+		return iface, ok
+	}
+
+	shape, ok := reg.methodShapes[decl.Key()]
+	if !ok {
+		return "", false
+	}
+	if method, ok := stdlibInterfaceMethods[decl.Name]; ok && method.shape == shape {
+		return "", true
+	}
+
+	return "", reg.UsageCount[interfaceKey(decl.Name, shape)] > 0
+}
+
+// collectInterfaceMethods records in InterfaceMethods every exported method of
+// the named types in pkgs that is part of an interface implemented by its
+// type. The interfaces are searched in all of the given packages, ifaces.
+func (reg *Registry) collectInterfaceMethods(pkgs []*types.Package, ifaces []*types.Package) {
+	type namedInterface struct {
+		name  string
+		iface *types.Interface
+	}
+
+	// index the interfaces by the methods they declare, error first
+	byMethod := make(map[string][]namedInterface)
+	add := func(name string, iface *types.Interface) {
+		for i := 0; i < iface.NumMethods(); i++ {
+			method := iface.Method(i).Name()
+			byMethod[method] = append(byMethod[method], namedInterface{name, iface})
+		}
+	}
+
+	errorType := types.Universe.Lookup("error").Type()
+	add("error", errorType.Underlying().(*types.Interface))
+
+	// the errors package checks for these with unnamed interfaces
+	for _, method := range []struct {
+		name          string
+		param, result types.Type
+	}{
+		{"Unwrap", nil, errorType},
+		{"Is", errorType, types.Typ[types.Bool]},
+		{"As", types.NewInterfaceType(nil, nil), types.Typ[types.Bool]},
+	} {
+		var params []*types.Var
+		if method.param != nil {
+			params = append(params, types.NewParam(0, nil, "", method.param))
+		}
+		results := []*types.Var{types.NewParam(0, nil, "", method.result)}
+		sig := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), types.NewTuple(results...), false)
+
+		iface := types.NewInterfaceType([]*types.Func{types.NewFunc(0, nil, method.name, sig)}, nil)
+		add(stdlibInterfaceMethods[method.name].iface, iface.Complete())
+	}
+
+	// prefer the names of public packages when reporting the interface
+	sort.Slice(ifaces, func(i, j int) bool {
+		iInternal, jInternal := isInternal(ifaces[i].Path()), isInternal(ifaces[j].Path())
+		if iInternal != jInternal {
+			return jInternal
+		}
+		return ifaces[i].Path() < ifaces[j].Path()
+	})
+
+	for _, pkg := range ifaces {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			// aliases are kept, encoding/json.Marshaler may alias another package
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+
+			if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+
+			// constraint interfaces with type sets cannot be implemented by methods
+			iface, ok := obj.Type().Underlying().(*types.Interface)
+			if ok && iface.NumMethods() > 0 && iface.IsMethodSet() {
+				add(pkg.Path()+"."+name, iface)
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			named := namedTypeOf(scope.Lookup(name))
+			if named == nil || named.TypeParams().Len() > 0 {
+				continue
+			}
+
+			if _, isInterface := named.Underlying().(*types.Interface); isInterface {
+				continue
+			}
+
+			ptr := types.NewPointer(named)
+			for i := 0; i < named.NumMethods(); i++ {
+				method := named.Method(i)
+				if !method.Exported() {
+					continue
+				}
+
+				for _, candidate := range byMethod[method.Name()] {
+					if types.Implements(named, candidate.iface) || types.Implements(ptr, candidate.iface) {
+						reg.InterfaceMethods[objectKey(method)] = candidate.name
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func isInternal(path string) bool {
+	return strings.HasPrefix(path, "internal/") || strings.Contains(path, "/internal/") || strings.HasSuffix(path, "/internal")
+}
+
+// namedTypeOf returns the type declared by obj, if obj declares a named type
+func namedTypeOf(obj types.Object) *types.Named {
+	typeName, ok := obj.(*types.TypeName)
+	if !ok || typeName.IsAlias() {
+		return nil
+	}
+
+	named, _ := typeName.Type().(*types.Named)
+	return named
+}
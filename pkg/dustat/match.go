@@ -0,0 +1,332 @@
+package dustat
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// matchGlob reports whether the slash-separated name matches the pattern.
+// Besides the syntax of path.Match, a "**" element matches any number of
+// path elements, including none.
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchPath reports whether the file or directory at file, or one of its
+// parent directories, matches the glob. A relative glob is relative to root.
+func matchPath(glob, root, file string) bool {
+	if !filepath.IsAbs(glob) {
+		glob = filepath.Join(root, glob)
+	}
+	glob = filepath.ToSlash(glob)
+
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+
+	name := filepath.ToSlash(file)
+	for {
+		if matchGlob(glob, name) {
+			return true
+		}
+
+		i := strings.LastIndex(name, "/")
+		if i <= 0 {
+			return false
+		}
+		name = name[:i]
+	}
+}
+
+// root returns the absolute path of the project
+func (reg *Registry) root() string {
+	if abs, err := filepath.Abs(reg.Path); err == nil {
+		return abs
+	}
+	return reg.Path
+}
+
+// excluded reports whether the directory at dir is left out of the analysis
+func (reg *Registry) excluded(dir string) bool {
+	for _, glob := range reg.Exclude {
+		if matchPath(glob, reg.root(), dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPackagePattern reports whether the slash-separated name matches a
+// package pattern of the go command, where "..." matches any string and a
+// trailing "/..." also matches the name without it: net/... matches net and
+// net/http.
+func matchPackagePattern(pattern, name string) bool {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\.\.\.`, `.*`, -1)
+	if strings.HasSuffix(re, `/.*`) {
+		re = re[:len(re)-len(`/.*`)] + `(/.*)?`
+	}
+	ok, _ := regexp.MatchString(`^`+re+`$`, name)
+	return ok
+}
+
+// reported reports whether decl belongs to a package selected by Packages
+func (reg *Registry) reported(decl Decl) bool {
+	if len(reg.Packages) == 0 {
+		return true
+	}
+
+	dir := filepath.Dir(decl.Pos.Filename)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return reg.selected(decl.Package, dir)
+}
+
+// selected reports whether the package with the import path pkgPath, in the
+// absolute directory dir, matches one of the Packages. A pattern starting with
+// ".", ".." or "/" is matched against the directory of the package, relative
+// ones being relative to Path, and the others against its import path.
+func (reg *Registry) selected(pkgPath, dir string) bool {
+	for _, pattern := range reg.Packages {
+		if IsPathPattern(pattern) {
+			if reg.matchDir([]string{pattern}, dir) {
+				return true
+			}
+			continue
+		}
+
+		if matchPackagePattern(pattern, pkgPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDir reports whether the absolute directory dir matches one of the
+// directory patterns, relative ones being relative to Path
+func (reg *Registry) matchDir(patterns []string, dir string) bool {
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(reg.root(), pattern)
+		}
+		if matchPackagePattern(filepath.ToSlash(pattern), filepath.ToSlash(dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternDir returns the absolute directory a directory pattern starts from,
+// the part before its first "..."
+func (reg *Registry) patternDir(pattern string) string {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(reg.root(), pattern)
+	}
+
+	i := strings.Index(pattern, "...")
+	if i < 0 {
+		return pattern
+	}
+
+	dir := pattern[:i]
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// IsPathPattern reports whether the package pattern names directories instead
+// of import paths, like the go command tells them apart
+func IsPathPattern(pattern string) bool {
+	return filepath.IsAbs(pattern) || pattern == "." || pattern == ".." ||
+		strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") ||
+		strings.HasPrefix(pattern, "."+string(filepath.Separator)) || strings.HasPrefix(pattern, ".."+string(filepath.Separator))
+}
+
+// ignored reports whether decl is hidden by the ignore lists
+func (reg *Registry) ignored(decl Decl, patterns []ignorePattern) bool {
+	for _, pattern := range patterns {
+		if pattern.match(decl, reg.root()) {
+			return true
+		}
+	}
+
+	for _, glob := range reg.IgnorePackages {
+		if matchGlob(glob, decl.Package) {
+			return true
+		}
+	}
+
+	for _, glob := range reg.IgnorePaths {
+		if matchPath(glob, reg.root(), decl.Pos.Filename) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignorePattern is a compiled entry of the ignore list, written as
+// [location:]symbol. The symbol is matched against the name of a declaration
+// and against its name qualified by the receiver type (Server.Start). The
+// location is matched against the import path of the package and against the
+// path of the file relative to the project, or one of its directories. Both
+// parts are globs, or regular expressions when they start with "~":
+//
+//	Test*                    any symbol starting with Test
+//	Server.*                 every method and field of Server
+//	internal/api/**:*Handler symbols ending in Handler in internal/api
+//	~^(Get|Set)[A-Z]         getters and setters
+type ignorePattern struct {
+	location, symbol string
+	locationRe       *regexp.Regexp
+	symbolRe         *regexp.Regexp
+}
+
+// compileIgnorePatterns compiles the patterns of an ignore list
+func compileIgnorePatterns(list map[string]struct{}) ([]ignorePattern, error) {
+	var patterns []ignorePattern
+	for entry := range list {
+		pattern, err := parseIgnorePattern(entry)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// parseIgnorePattern compiles a pattern, see cutIgnorePattern
+func parseIgnorePattern(entry string) (ignorePattern, error) {
+	var pattern ignorePattern
+
+	location, symbol, ok := cutIgnorePattern(entry)
+	if !ok {
+		location, symbol = "", entry
+	}
+
+	if symbol == "" {
+		return pattern, fmt.Errorf("invalid ignore pattern %q: missing symbol", entry)
+	}
+
+	for _, part := range []struct {
+		text string
+		glob *string
+		re   **regexp.Regexp
+	}{
+		{location, &pattern.location, &pattern.locationRe},
+		{symbol, &pattern.symbol, &pattern.symbolRe},
+	} {
+		if strings.HasPrefix(part.text, "~") {
+			re, err := regexp.Compile(part.text[1:])
+			if err != nil {
+				return pattern, fmt.Errorf("invalid ignore pattern %q: %v", entry, err)
+			}
+			*part.re = re
+			continue
+		}
+
+		if _, err := path.Match(part.text, ""); err != nil {
+			return pattern, fmt.Errorf("invalid ignore pattern %q: %v", entry, err)
+		}
+		*part.glob = part.text
+	}
+
+	return pattern, nil
+}
+
+// cutIgnorePattern separates the location and the symbol of a pattern at the
+// first colon. In a regular expression, the colons within parentheses or
+// brackets and the escaped ones do not separate them: ~^(?:Get|Set)[A-Z] is a
+// symbol.
+func cutIgnorePattern(entry string) (string, string, bool) {
+	if !strings.HasPrefix(entry, "~") {
+		return strings.Cut(entry, ":")
+	}
+
+	depth, inClass := 0, false
+	for i := 1; i < len(entry); i++ {
+		switch c := entry[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+			// a closing bracket first in the class is part of it: []a] or [^]a]
+			if i+1 < len(entry) && entry[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(entry) && entry[i+1] == ']' {
+				i++
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ':' && depth <= 0:
+			return entry[:i], entry[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+func (p ignorePattern) match(decl Decl, root string) bool {
+	return p.matchSymbol(decl) && p.matchLocation(decl, root)
+}
+
+func (p ignorePattern) matchSymbol(decl Decl) bool {
+	if p.symbolRe != nil {
+		return p.symbolRe.MatchString(decl.Name) || p.symbolRe.MatchString(decl.Symbol())
+	}
+
+	if ok, _ := path.Match(p.symbol, decl.Name); ok {
+		return true
+	}
+	ok, _ := path.Match(p.symbol, decl.Symbol())
+	return ok
+}
+
+func (p ignorePattern) matchLocation(decl Decl, root string) bool {
+	if p.locationRe != nil {
+		file := decl.Pos.Filename
+		if abs, err := filepath.Abs(file); err == nil {
+			if rel, err := filepath.Rel(root, abs); err == nil {
+				file = rel
+			}
+		}
+		return p.locationRe.MatchString(decl.Package) || p.locationRe.MatchString(filepath.ToSlash(file))
+	}
+
+	if p.location == "" {
+		return true
+	}
+
+	return matchGlob(p.location, decl.Package) || matchPath(p.location, root, decl.Pos.Filename)
+}
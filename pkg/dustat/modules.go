@@ -0,0 +1,409 @@
+package dustat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Module is a Go module of the project. Each module is analyzed as its own
+// unit: a use only counts for the declarations of the modules it can reach.
+type Module struct {
+	Path      string            // Path is the module path from the go.mod file
+	Dir       string            // Dir is the absolute directory of the go.mod file
+	Requires  map[string]bool   // Requires holds the paths of the required modules
+	Replaces  map[string]string // Replaces maps the required modules replaced by a local directory to that directory
+	Workspace bool              // Workspace is set for the modules used by the go.work file
+	Consumer  bool              // Consumer is set for the modules of the Consumers directories
+}
+
+// reach is how a module reaches the declarations of another one
+type reach int
+
+const (
+	reachNone    reach = iota // the module cannot import the other one
+	reachDirect               // same module, same workspace or a requirement
+	reachReplace              // a requirement replaced by a local directory
+)
+
+// loadModules fills Modules with the module containing Path, the modules
+// nested in it, the modules of the go.work workspace Path is part of and those
+// of the UsageFrom and Consumers directories. The workspace modules and the
+// UsageFrom and Consumers directories outside of Path are walked for uses only.
+func (reg *Registry) loadModules() error {
+	reg.Modules = nil
+	reg.modules = make(map[string]*Module)
+	reg.fileModules = make(map[string]*Module)
+	reg.usageDirs = nil
+
+	root, err := filepath.Abs(reg.Path)
+	if err != nil {
+		return err
+	}
+
+	// in a workspace, the module containing Path only counts when it is used
+	work := findWorkspace(root)
+	if modRoot := findModule(root); modRoot != "" && work == "" {
+		if err := reg.addModule(modRoot); err != nil {
+			return err
+		}
+	}
+
+	if err := reg.addModulesIn(root); err != nil {
+		return err
+	}
+
+	for _, pattern := range reg.UsageFrom {
+		if err := reg.addUsageFrom(root, reg.patternDir(pattern), false); err != nil {
+			return err
+		}
+	}
+	for _, dir := range reg.Consumers {
+		if err := reg.addUsageFrom(root, reg.patternDir(dir), true); err != nil {
+			return err
+		}
+	}
+
+	if work != "" {
+		if err := reg.addWorkspace(root, work); err != nil {
+			return err
+		}
+	}
+
+	if len(reg.Consumers) > 0 && len(reg.ownModules()) == 0 {
+		return fmt.Errorf("no module found in %s, the imports of the consumers cannot be resolved", reg.Path)
+	}
+
+	return nil
+}
+
+// addUsageFrom adds the modules of dir, walked for uses only when it is
+// outside of root. The modules of a consumer are marked as such.
+func (reg *Registry) addUsageFrom(root, dir string, consumer bool) error {
+	if within(root, dir) && !consumer {
+		return nil
+	}
+
+	modRoot := findModule(dir)
+	if modRoot != "" {
+		if err := reg.addModule(modRoot); err != nil {
+			return err
+		}
+	}
+	if err := reg.addModulesIn(dir); err != nil {
+		return err
+	}
+
+	if consumer {
+		for _, mod := range reg.Modules {
+			if within(dir, mod.Dir) || (mod.Dir == modRoot && !within(mod.Dir, root)) {
+				mod.Consumer = true
+			}
+		}
+	}
+
+	if !within(root, dir) {
+		reg.addUsageDir(dir)
+	}
+	return nil
+}
+
+// addWorkspace adds the modules used by the go.work file at work
+func (reg *Registry) addWorkspace(root, work string) error {
+	data, err := os.ReadFile(work)
+	if err != nil {
+		return err
+	}
+	wf, err := modfile.ParseWork(work, data, nil)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", work, err)
+	}
+
+	for _, use := range wf.Use {
+		dir := use.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(work), dir)
+		}
+		if err := reg.addModule(dir); err != nil {
+			return err
+		}
+
+		mod := reg.moduleIn(dir)
+		mod.Workspace = true
+		for _, replace := range wf.Replace {
+			addReplace(mod, filepath.Dir(work), replace)
+		}
+
+		if !within(root, mod.Dir) && !within(mod.Dir, root) {
+			reg.addUsageDir(mod.Dir)
+		}
+	}
+
+	return nil
+}
+
+// addModulesIn adds the modules whose go.mod file is under dir
+func (reg *Registry) addModulesIn(dir string) error {
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != dir && (skipDir(info.Name()) || reg.excluded(path)) {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == "go.mod" {
+			return reg.addModule(filepath.Dir(path))
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("error looking for modules: %v", err)
+	}
+	return nil
+}
+
+// addUsageDir adds dir to the directories walked for uses only, unless it is
+// already part of one of them. The ones it contains are dropped.
+func (reg *Registry) addUsageDir(dir string) {
+	var dirs []string
+	for _, usageDir := range reg.usageDirs {
+		if within(usageDir, dir) {
+			return
+		}
+		if !within(dir, usageDir) {
+			dirs = append(dirs, usageDir)
+		}
+	}
+
+	reg.usageDirs = append(dirs, dir)
+	sort.Strings(reg.usageDirs)
+}
+
+// ownModules returns the modules of the project: the module containing Path
+// and the modules nested in it, but not its consumers
+func (reg *Registry) ownModules() []*Module {
+	root := reg.root()
+
+	var own []*Module
+	for _, mod := range reg.Modules {
+		if !mod.Consumer && (within(root, mod.Dir) || within(mod.Dir, root)) {
+			own = append(own, mod)
+		}
+	}
+	return own
+}
+
+// addModule adds the module whose go.mod file is in dir, once
+func (reg *Registry) addModule(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if reg.moduleIn(dir) != nil {
+		return nil
+	}
+
+	path := filepath.Join(dir, "go.mod")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	mf, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if mf.Module == nil {
+		return fmt.Errorf("no module directive in %s", path)
+	}
+
+	mod := &Module{
+		Path:     mf.Module.Mod.Path,
+		Dir:      dir,
+		Requires: make(map[string]bool),
+		Replaces: make(map[string]string),
+	}
+	for _, require := range mf.Require {
+		mod.Requires[require.Mod.Path] = true
+	}
+	for _, replace := range mf.Replace {
+		addReplace(mod, dir, replace)
+	}
+
+	reg.Modules = append(reg.Modules, mod)
+	sort.Slice(reg.Modules, func(i, j int) bool {
+		return reg.Modules[i].Dir < reg.Modules[j].Dir
+	})
+	reg.modules[mod.Path] = mod
+	return nil
+}
+
+// addReplace records replace when it points to a local directory, relative to dir
+func addReplace(mod *Module, dir string, replace *modfile.Replace) {
+	if replace.New.Version != "" || !modfile.IsDirectoryPath(replace.New.Path) {
+		return
+	}
+
+	target := replace.New.Path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	mod.Replaces[replace.Old.Path] = target
+}
+
+// ProjectRoot returns the directory whose packages are looked at for the uses
+// of the packages in dir: the directory of the go.work file of its workspace,
+// else the one of the go.mod file of its module, else dir itself. It is dir as
+// well when dir is in a vendor, testdata or hidden directory of that root,
+// which would not be walked from it.
+func ProjectRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+
+	root := abs
+	if work := findWorkspace(abs); work != "" {
+		root = filepath.Dir(work)
+	} else if modRoot := findModule(abs); modRoot != "" {
+		root = modRoot
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return abs
+	}
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		if name != "." && skipDir(name) {
+			return abs
+		}
+	}
+	return root
+}
+
+// findWorkspace returns the go.work file used for dir, like the go command
+// does: the one named by GOWORK, or the first one found walking up from dir.
+func findWorkspace(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+
+	for {
+		path := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// within reports whether path is dir or inside of it, both being absolute
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// containsPath reports whether dir contains Path, which is then analyzed as
+// part of the module in dir
+func (reg *Registry) containsPath(dir string) bool {
+	root, err := filepath.Abs(reg.Path)
+	return err == nil && dir != root && within(dir, root)
+}
+
+// skipDir reports whether the directory is left out of the walk: vendor,
+// testdata and hidden directories
+func skipDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")
+}
+
+// moduleIn returns the module whose go.mod file is in dir
+func (reg *Registry) moduleIn(dir string) *Module {
+	for _, mod := range reg.Modules {
+		if mod.Dir == dir {
+			return mod
+		}
+	}
+	return nil
+}
+
+// moduleOf returns the innermost module containing the file or directory at
+// path, or nil when it is in none of the Modules.
+func (reg *Registry) moduleOf(path string) *Module {
+	if mod, ok := reg.fileModules[path]; ok {
+		return mod
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+
+	var found *Module
+	for _, mod := range reg.Modules {
+		if !within(mod.Dir, abs) {
+			continue
+		}
+		if found == nil || len(mod.Dir) > len(found.Dir) {
+			found = mod
+		}
+	}
+
+	if reg.fileModules != nil {
+		reg.fileModules[path] = found
+	}
+	return found
+}
+
+// importPathOf returns the import path of the package in dir, using the
+// module containing it.
+func (reg *Registry) importPathOf(dir string) string {
+	return importPath(reg.moduleOf(dir), reg.Path, dir)
+}
+
+// reach returns how the module user reaches the declarations of the module
+// declaring them. Uses are always counted when a module is unknown, and those
+// of the consumers whatever way they reach the module.
+func (reg *Registry) reach(user string, declaring *Module) reach {
+	mod, ok := reg.modules[user]
+	if !ok || declaring == nil || user == declaring.Path {
+		return reachDirect
+	}
+
+	switch {
+	case mod.Consumer:
+		return reachDirect
+	case mod.Workspace && declaring.Workspace:
+		return reachDirect
+	case mod.Replaces[declaring.Path] != "":
+		return reachReplace
+	case mod.Requires[declaring.Path]:
+		return reachDirect
+	}
+	return reachNone
+}
+
+// moduleUse records a use of key outside of tests by the module of file
+func (reg *Registry) moduleUse(key, file string) {
+	mod := reg.moduleOf(file)
+	if mod == nil {
+		return
+	}
+
+	uses, ok := reg.moduleUses[key]
+	if !ok {
+		uses = make(map[string]int)
+		reg.moduleUses[key] = uses
+	}
+	uses[mod.Path]++
+}
@@ -0,0 +1,61 @@
+package dustat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Reporter writes the result of an analysis in one output format
+type Reporter interface {
+	Report(reg *Registry, w io.Writer) error
+}
+
+// ReporterFunc adapts a function to the Reporter interface
+type ReporterFunc func(reg *Registry, w io.Writer) error
+
+func (f ReporterFunc) Report(reg *Registry, w io.Writer) error {
+	return f(reg, w)
+}
+
+// Output sends the result to a writer through a reporter
+type Output struct {
+	Reporter Reporter
+	Writer   io.Writer
+}
+
+// reporters holds the reporters selectable with --format, by name
+var reporters = map[string]Reporter{
+	"text":       ReporterFunc((*Registry).writeText),
+	"json":       ReporterFunc((*Registry).writeJSON),
+	"sarif":      ReporterFunc((*Registry).writeSARIF),
+	"checkstyle": ReporterFunc((*Registry).writeCheckstyle),
+	"junit":      ReporterFunc((*Registry).writeJUnit),
+	"github":     ReporterFunc((*Registry).writeGitHub),
+}
+
+// RegisterReporter makes a reporter available under the format name,
+// replacing the one registered before under the same name.
+func RegisterReporter(format string, reporter Reporter) {
+	reporters[format] = reporter
+}
+
+// LookupReporter returns the reporter registered for the format
+func LookupReporter(format string) (Reporter, error) {
+	reporter, ok := reporters[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return reporter, nil
+}
+
+// Formats returns the sorted names of the registered reporters
+func Formats() []string {
+	var formats []string
+	for format := range reporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
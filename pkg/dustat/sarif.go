@@ -0,0 +1,209 @@
+package dustat
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sarifRootID is the base of the artifact locations, set to the project root
+const sarifRootID = "%SRCROOT%"
+
+// sarifRule describes a category of findings as a SARIF reporting descriptor
+type sarifRule struct {
+	category    Category
+	name        string
+	description string
+	level       string
+}
+
+var sarifRules = []sarifRule{
+	{CategoryUnused, "UnusedExport", "Exported identifier is never used", "warning"},
+	{CategoryInterfaceOnly, "InterfaceOnlyMethod", "Exported method is only used to satisfy an interface", "note"},
+	{CategoryTaggedField, "UnusedTaggedField", "Exported field with a struct tag is never used, it may be used through reflection", "note"},
+	{CategoryTestOnly, "TestOnlyExport", "Exported identifier is only used in tests", "note"},
+	{CategoryGenerated, "UnusedGeneratedExport", "Exported identifier in a generated file is never used", "note"},
+	{CategoryReplaceOnly, "ReplaceOnlyExport", "Exported identifier is only used by modules that replace its module with a local directory", "note"},
+	{CategoryUnusedSuppression, "UnusedSuppression", "Suppression directive does not hide any finding", "warning"},
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string                     `json:"name"`
+	InformationURI string                     `json:"informationUri"`
+	Rules          []sarifReportingDescriptor `json:"rules"`
+}
+
+type sarifReportingDescriptor struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// writeSARIF writes the result as a SARIF 2.1.0 log
+func (reg *Registry) writeSARIF(w io.Writer) error {
+	root := reg.root()
+
+	driver := sarifDriver{Name: "dustat", InformationURI: "https://github.com/tompston/dustat"}
+	ruleIndex := make(map[Category]int)
+	for i, rule := range sarifRules {
+		ruleIndex[rule.category] = i
+		driver.Rules = append(driver.Rules, sarifReportingDescriptor{
+			ID:                   string(rule.category),
+			Name:                 rule.name,
+			ShortDescription:     sarifMessage{rule.description},
+			DefaultConfiguration: sarifConfiguration{rule.level},
+		})
+	}
+
+	decls := append([]Decl{}, reg.Result...)
+	sort.Slice(decls, func(i, j int) bool {
+		if decls[i].Pos.Filename != decls[j].Pos.Filename {
+			return decls[i].Pos.Filename < decls[j].Pos.Filename
+		}
+		return decls[i].Pos.Offset < decls[j].Pos.Offset
+	})
+
+	results := []sarifResult{}
+	for _, decl := range decls {
+		index := ruleIndex[decl.Category]
+		rule := sarifRules[index]
+
+		location := sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: artifactLocation(root, decl.Pos.Filename),
+				Region: sarifRegion{
+					StartLine:   decl.Pos.Line,
+					StartColumn: decl.Pos.Column,
+					EndLine:     decl.End.Line,
+					EndColumn:   decl.End.Column,
+				},
+			},
+		}
+		if decl.Name != "" {
+			location.LogicalLocations = []sarifLogicalLocation{{
+				FullyQualifiedName: decl.Key(),
+				Kind:               logicalKind(decl.Kind),
+			}}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:              string(rule.category),
+			RuleIndex:           index,
+			Level:               rule.level,
+			Message:             sarifMessage{decl.Message()},
+			Locations:           []sarifLocation{location},
+			PartialFingerprints: map[string]string{"dustat/v1": decl.Fingerprint()},
+		})
+	}
+
+	rootURI := url.URL{Scheme: "file", Path: filepath.ToSlash(root) + "/"}
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:               sarifTool{Driver: driver},
+			OriginalURIBaseIDs: map[string]sarifArtifactLocation{sarifRootID: {URI: rootURI.String()}},
+			Results:            results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// artifactLocation returns the location of file relative to the project root,
+// or its absolute file URI when it is outside of the project.
+func artifactLocation(root, file string) sarifArtifactLocation {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+
+	rel, err := filepath.Rel(root, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		uri := url.URL{Scheme: "file", Path: filepath.ToSlash(file)}
+		return sarifArtifactLocation{URI: uri.String()}
+	}
+
+	uri := url.URL{Path: filepath.ToSlash(rel)}
+	return sarifArtifactLocation{URI: uri.String(), URIBaseID: sarifRootID}
+}
+
+// logicalKind returns the SARIF logical location kind of a declaration kind
+func logicalKind(kind Kind) string {
+	switch kind {
+	case KindFunc:
+		return "function"
+	case KindMethod, KindField:
+		return "member"
+	case KindConst, KindVar:
+		return "variable"
+	case KindPackage:
+		return "package"
+	}
+	return string(kind)
+}
@@ -0,0 +1,178 @@
+package dustat
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// suppression is a //dustat:ignore or //nolint:dustat directive found in the
+// comments of a declaration, a declaration group or a package clause.
+type suppression struct {
+	directive string
+	pos       token.Position
+	end       token.Position
+	pkg       string
+	name      string // name of the first declaration covered, empty for a package clause
+	recv      string
+	kind      Kind
+	used      bool // used is set once the directive hides a finding
+}
+
+// isDirective reports whether the comment is a suppression directive for
+// dustat. Like golangci-lint, a bare //nolint applies to every linter. The
+// directive may be followed by a reason:
+//
+//	//dustat:ignore kept for the plugin API
+//	//nolint:dustat,unused // kept for the plugin API
+func isDirective(text string) bool {
+	if !strings.HasPrefix(text, "//") {
+		return false
+	}
+	directive, _, _ := strings.Cut(text[2:], " ")
+
+	switch {
+	case directive == "dustat:ignore", directive == "nolint":
+		return true
+
+	case strings.HasPrefix(directive, "nolint:"):
+		for _, linter := range strings.Split(strings.TrimPrefix(directive, "nolint:"), ",") {
+			if linter == "dustat" || linter == "all" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fileDirectives finds the suppression directives in the comments of a file
+type fileDirectives struct {
+	fset   *token.FileSet
+	byLine map[int][]*ast.Comment
+}
+
+func newFileDirectives(file *ast.File, fset *token.FileSet) *fileDirectives {
+	fd := &fileDirectives{fset: fset, byLine: make(map[int][]*ast.Comment)}
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			line := fset.Position(comment.Pos()).Line
+			fd.byLine[line] = append(fd.byLine[line], comment)
+		}
+	}
+	return fd
+}
+
+// find returns the first directive in the given comment groups, or on the
+// same line as pos.
+func (fd *fileDirectives) find(pos token.Pos, groups ...*ast.CommentGroup) *ast.Comment {
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, comment := range group.List {
+			if isDirective(comment.Text) {
+				return comment
+			}
+		}
+	}
+
+	for _, comment := range fd.byLine[fd.fset.Position(pos).Line] {
+		if isDirective(comment.Text) {
+			return comment
+		}
+	}
+
+	return nil
+}
+
+// addSuppression records the directive, unless it was already seen while
+// loading another build configuration.
+func (reg *Registry) addSuppression(comment *ast.Comment, fset *token.FileSet, pkg string) *suppression {
+	if reg.suppressions == nil {
+		reg.suppressions = make(map[string]*suppression)
+	}
+
+	pos := fset.Position(comment.Pos())
+	if sup, ok := reg.suppressions[pos.String()]; ok {
+		return sup
+	}
+
+	sup := &suppression{
+		directive: comment.Text,
+		pos:       pos,
+		end:       fset.Position(comment.End()),
+		pkg:       pkg,
+	}
+	reg.suppressions[pos.String()] = sup
+	return sup
+}
+
+// suppress marks the declaration as covered by the directive
+func (reg *Registry) suppress(decl Decl, sup *suppression) {
+	if reg.suppressedDecls == nil {
+		reg.suppressedDecls = make(map[string]*suppression)
+	}
+
+	if sup.kind == "" {
+		sup.name, sup.recv, sup.kind = decl.Name, decl.Recv, decl.Kind
+	}
+	reg.suppressedDecls[decl.Key()] = sup
+}
+
+// suppressPackage marks every declaration of the package as covered
+func (reg *Registry) suppressPackage(pkg string, sup *suppression) {
+	if reg.suppressedPkgs == nil {
+		reg.suppressedPkgs = make(map[string]*suppression)
+	}
+
+	sup.kind = KindPackage
+	reg.suppressedPkgs[pkg] = sup
+}
+
+// suppressed returns the directive hiding decl, preferring the one closest to
+// the declaration.
+func (reg *Registry) suppressed(decl Decl) *suppression {
+	if sup, ok := reg.suppressedDecls[decl.Key()]; ok {
+		return sup
+	}
+	return reg.suppressedPkgs[decl.Package]
+}
+
+// unusedSuppressions returns the directives that did not hide any finding,
+// as CategoryUnusedSuppression declarations positioned at the directive. The
+// directives of the declarations that are not reported, out of Packages or
+// only parsed for their uses, are left out: they were not checked.
+func (reg *Registry) unusedSuppressions() []Decl {
+	var decls []Decl
+	for _, sup := range reg.suppressions {
+		if sup.used {
+			continue
+		}
+
+		decl := Decl{
+			Name:      sup.name,
+			Recv:      sup.recv,
+			Package:   sup.pkg,
+			Kind:      sup.kind,
+			Category:  CategoryUnusedSuppression,
+			Directive: sup.directive,
+			Pos:       sup.pos,
+			End:       sup.end,
+			LineCount: sup.end.Line - sup.pos.Line + 1,
+		}
+		if reg.usageOnly(decl) || !reg.reported(decl) {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+
+	sort.Slice(decls, func(i, j int) bool {
+		if decls[i].Pos.Filename != decls[j].Pos.Filename {
+			return decls[i].Pos.Filename < decls[j].Pos.Filename
+		}
+		return decls[i].Pos.Offset < decls[j].Pos.Offset
+	})
+	return decls
+}
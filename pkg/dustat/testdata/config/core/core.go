@@ -0,0 +1,5 @@
+package core
+
+func Dead() {}
+
+func Kept() {}
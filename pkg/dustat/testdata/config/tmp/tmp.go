@@ -0,0 +1,7 @@
+package tmp
+
+import "example.com/config/core"
+
+func Scratch() {
+	core.Dead()
+}
@@ -0,0 +1,10 @@
+package main
+
+import "example.com/shared"
+
+func main() {
+	shared.Parse()
+
+	c := shared.Client{}
+	c.Do()
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/shared"
+)
+
+// Version shares its name with a constant of the library
+const Version = "2.0.0"
+
+func Helper() {}
+
+func main() {
+	shared.Parse()
+	shared.Format()
+
+	Helper()
+	fmt.Println(Version)
+}
@@ -0,0 +1,7 @@
+module example.com/consumer-c
+
+go 1.18
+
+require example.com/shared v1.0.0
+
+replace example.com/shared => ../shared
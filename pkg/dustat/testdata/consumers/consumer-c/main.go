@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+
+	. "example.com/shared"
+)
+
+type Worker struct{}
+
+// Helper is a method, not a use of the Helper of the library
+func (Worker) Helper() {}
+
+func main() {
+	Worker{}.Helper()
+	fmt.Println(Version)
+}
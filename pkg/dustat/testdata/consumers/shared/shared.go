@@ -0,0 +1,20 @@
+package shared
+
+const Version = "1.0.0"
+
+func Parse() {}
+
+func Format() {}
+
+// Internal is only used inside the library
+func Internal() {}
+
+func Helper() {
+	Internal()
+}
+
+type Client struct{}
+
+func (Client) Do() {}
+
+func (Client) Retry() {}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/fields/model"
+)
+
+func main() {
+	u := model.User{Email: "a@b.c"}
+	fmt.Println(u.Name)
+
+	p := model.Pair[string, int]{}
+	fmt.Println(p.Key, model.Point{1, 2})
+}
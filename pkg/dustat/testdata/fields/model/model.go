@@ -0,0 +1,18 @@
+package model
+
+type User struct {
+	Name     string // read by main
+	Email    string // only set in a composite literal
+	Age      int    // never used
+	Password string `json:"password"`
+	internal int
+}
+
+type Pair[K comparable, V any] struct {
+	Key   K // read through an instance
+	Value V // never used
+}
+
+type Point struct {
+	X, Y int // both set by an unkeyed literal
+}
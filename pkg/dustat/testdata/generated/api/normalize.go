@@ -0,0 +1,9 @@
+package api
+
+import "strings"
+
+// Normalize is only called from generated code.
+func Normalize(s string) string { return strings.TrimSpace(s) }
+
+// Unused is never called.
+func Unused() {}
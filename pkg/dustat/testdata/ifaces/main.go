@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/ifaces/shapes"
+)
+
+func main() {
+	var s shapes.Shape = shapes.Square{Side: 2}
+	fmt.Println(s.Area(), shapes.Doc{})
+
+	var err error = &shapes.NotFound{}
+	fmt.Println(err)
+
+	var solid shapes.Solid
+	fmt.Println(solid, shapes.Pool{})
+}
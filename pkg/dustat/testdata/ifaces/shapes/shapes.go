@@ -0,0 +1,46 @@
+package shapes
+
+import "strconv"
+
+type Shape interface {
+	Area() float64
+}
+
+type Square struct {
+	Side float64
+}
+
+// Area is only called through the Shape interface.
+func (s Square) Area() float64 { return s.Side * s.Side }
+
+// Perimeter is never called.
+func (s Square) Perimeter() float64 { return 4 * s.Side }
+
+// String satisfies fmt.Stringer, which this package never imports.
+func (s Square) String() string { return strconv.FormatFloat(s.Side, 'f', -1, 64) }
+
+type NotFound struct{}
+
+// Error satisfies the error interface through a pointer receiver.
+func (e *NotFound) Error() string { return "not found" }
+
+type Doc struct{}
+
+// MarshalJSON satisfies encoding/json.Marshaler.
+func (d Doc) MarshalJSON() ([]byte, error) { return []byte("{}"), nil }
+
+// Solid is implemented by none of the types.
+type Solid interface {
+	Volume() float64
+}
+
+// Volume has the name of the method of Solid, but takes a parameter.
+func (s Square) Volume(depth float64) float64 { return s.Area() * depth }
+
+// Pool has methods named like those of sort.Interface and
+// database/sql/driver.Valuer, which it does not implement.
+type Pool struct{}
+
+func (Pool) Len(x, y int) string { return "" }
+
+func (Pool) Value() int { return 0 }
@@ -0,0 +1,14 @@
+package main
+
+import "example.com/methods/server"
+
+func main() {
+	s := &server.Server{}
+	s.Start()
+
+	w := server.Wrapper{Server: s}
+	w.Stop()
+
+	var v server.Value
+	_ = v.Get()
+}
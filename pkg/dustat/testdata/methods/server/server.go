@@ -0,0 +1,24 @@
+package server
+
+type Server struct{}
+
+// Start is called through a pointer.
+func (s *Server) Start() {}
+
+// Stop is called through the embedding Wrapper.
+func (s Server) Stop() {}
+
+// Restart is never called.
+func (s *Server) Restart() {}
+
+// Start shares its name with a method, but is never called.
+func Start() {}
+
+type Wrapper struct {
+	*Server
+}
+
+type Value struct{}
+
+// Get is called on a concrete value.
+func (v Value) Get() int { return 0 }
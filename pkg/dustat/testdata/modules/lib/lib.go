@@ -0,0 +1,11 @@
+package lib
+
+func Used() {}
+
+func Unused() {}
+
+// Replaced is only used by a module that replaces lib with this directory
+func Replaced() {}
+
+// Helper shares its name with a function of an unrelated module
+func Helper() {}
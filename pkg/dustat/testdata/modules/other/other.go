@@ -0,0 +1,9 @@
+package other
+
+func helper() {
+	Helper()
+}
+
+func Helper() {
+	helper()
+}
@@ -0,0 +1,7 @@
+package plugin
+
+import "example.com/lib"
+
+func init() {
+	lib.Replaced()
+}
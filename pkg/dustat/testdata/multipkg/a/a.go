@@ -0,0 +1,7 @@
+package a
+
+type Config struct {
+	Name string
+}
+
+func New() {}
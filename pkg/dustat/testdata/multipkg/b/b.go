@@ -0,0 +1,7 @@
+package b
+
+type Config struct {
+	Addr string
+}
+
+func New() {}
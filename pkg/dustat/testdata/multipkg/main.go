@@ -0,0 +1,11 @@
+package main
+
+import (
+	"example.com/multipkg/a"
+	"example.com/multipkg/b"
+)
+
+func main() {
+	a.New()
+	b.New()
+}
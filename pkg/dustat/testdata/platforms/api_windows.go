@@ -0,0 +1,4 @@
+package plat
+
+// WinAPI is only declared on windows, where nothing uses it.
+func WinAPI() {}
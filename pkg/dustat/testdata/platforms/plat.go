@@ -0,0 +1,10 @@
+package plat
+
+// Common is never used on any platform.
+func Common() {}
+
+func LinuxOnly() {}
+
+func WindowsOnly() {}
+
+func TaggedOnly() {}
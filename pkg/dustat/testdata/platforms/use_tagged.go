@@ -0,0 +1,5 @@
+//go:build integration
+
+package plat
+
+func useTagged() { TaggedOnly() }
@@ -0,0 +1,3 @@
+package plat
+
+func useWindows() { WindowsOnly() }
@@ -0,0 +1,7 @@
+package main
+
+import "example.com/scope/pkg/lib"
+
+func main() {
+	lib.UsedByCmd()
+}
@@ -0,0 +1,11 @@
+package lib
+
+func UsedByCmd() {}
+
+func UsedByTool() {}
+
+func UsedBySibling() {}
+
+func UsedInPkg() {}
+
+func Unused() {}
@@ -0,0 +1,7 @@
+package other
+
+import "example.com/scope/pkg/lib"
+
+func init() {
+	lib.UsedInPkg()
+}
@@ -0,0 +1,5 @@
+module example.com/sibling
+
+go 1.18
+
+require example.com/scope v0.1.0
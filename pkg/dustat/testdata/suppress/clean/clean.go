@@ -0,0 +1,4 @@
+//nolint:dustat
+package clean
+
+func Helper() {}
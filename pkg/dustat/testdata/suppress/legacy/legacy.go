@@ -0,0 +1,4 @@
+//dustat:ignore the whole package is deprecated
+package legacy
+
+func Old() {}
@@ -0,0 +1,27 @@
+package lib
+
+//dustat:ignore kept for the plugin API
+func Kept() {}
+
+func Dead() {} //nolint:dustat // removed in v2
+
+//nolint:dustat
+const (
+	GroupA = 1
+	GroupB = 2
+)
+
+var (
+	//nolint:unused,dustat
+	Single = 1
+	Plain  = 2
+)
+
+//dustat:ignore no longer true
+func Used() {}
+
+//nolint:unused
+func OtherLinter() {}
+
+//dustat:ignored is not a directive
+func NotDirective() {}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"example.com/suppress/clean"
+	"example.com/suppress/lib"
+)
+
+func main() {
+	lib.Used()
+	clean.Helper()
+}
@@ -0,0 +1,11 @@
+package lib_test
+
+import (
+	"testing"
+
+	"example.com/tests/lib"
+)
+
+func TestHelperExternal(t *testing.T) {
+	_ = lib.Helper()
+}
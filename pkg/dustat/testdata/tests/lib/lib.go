@@ -0,0 +1,10 @@
+package lib
+
+// Prod is used by the main package.
+func Prod() {}
+
+// Helper is only used by the tests.
+func Helper() int { return 1 }
+
+// Dead is never used.
+func Dead() {}
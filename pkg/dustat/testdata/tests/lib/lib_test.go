@@ -0,0 +1,12 @@
+package lib
+
+import "testing"
+
+// Fixture is declared in a test file, so it is not part of the package API.
+var Fixture = 2
+
+func TestHelper(t *testing.T) {
+	if Helper() != 1 {
+		t.Fatal("unexpected value")
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+import "example.com/tests/lib"
+
+func main() {
+	lib.Prod()
+}
@@ -0,0 +1,7 @@
+package main
+
+import "example.com/typed"
+
+func main() {
+	_ = typed.Used()
+}
@@ -0,0 +1,16 @@
+package typed
+
+// Close is never called, only shadowed by a local variable.
+func Close() {}
+
+// A is never used, only a struct field shares its name.
+var A = 1
+
+type Item struct {
+	A string
+}
+
+func Used() string {
+	Close := Item{}
+	return Close.A
+}
@@ -0,0 +1,7 @@
+package main
+
+import "example.com/badimport/missing"
+
+func main() {
+	missing.Run()
+}
@@ -0,0 +1,10 @@
+package main
+
+// Count does not type-check, but its uses can still be resolved
+var Count int = "one"
+
+func main() {
+	_ = Count
+}
+
+func Unused() {}
@@ -0,0 +1,500 @@
+package dustat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// listedPackage is the subset of the `go list -json` output needed to load
+// packages with type information.
+type listedPackage struct {
+	Dir          string
+	ImportPath   string
+	Export       string
+	ForTest      string
+	DepOnly      bool
+	Standard     bool
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	ImportMap    map[string]string
+}
+
+// goList runs `go list -e -json` for the build configuration with the given
+// arguments in unit and decodes the stream of package objects it prints.
+func goList(unit typedUnit, config BuildConfig, args ...string) ([]listedPackage, error) {
+	env, flags := config.goEnv()
+
+	cmd := exec.Command("go", append(append([]string{"list", "-e", "-json"}, flags...), args...)...)
+	cmd.Dir = unit.dir
+	cmd.Env = append(append(os.Environ(), unit.env...), env...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list failed: %v\n%s", err, stderr.String())
+	}
+
+	var pkgs []listedPackage
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, fmt.Errorf("error decoding go list output: %v", err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	return pkgs, nil
+}
+
+// typedImporter resolves imports to the project packages that were already
+// type-checked from source, and to compiler export data for everything else.
+type typedImporter struct {
+	checked   map[string]*types.Package
+	override  map[string]*types.Package
+	importMap map[string]string
+	gc        types.Importer
+}
+
+func (imp *typedImporter) Import(path string) (*types.Package, error) {
+	if mapped, ok := imp.importMap[path]; ok {
+		path = mapped
+	}
+
+	if pkg, ok := imp.override[path]; ok {
+		return pkg, nil
+	}
+
+	if pkg, ok := imp.checked[path]; ok {
+		return pkg, nil
+	}
+
+	return imp.gc.Import(path)
+}
+
+// typedUnit is a directory `go list ./...` is run in, with the environment
+// selecting its module or workspace
+type typedUnit struct {
+	dir string
+	env []string
+}
+
+// typedUnits returns Path, when it is inside a module, and the modules and
+// directories that `go list ./...` does not reach from there: the modules
+// nested in Path, the workspace modules outside of it and the UsageFrom
+// directories. The modules that are not part of the
+// workspace are loaded on their own, with GOWORK=off.
+func (reg *Registry) typedUnits() []typedUnit {
+	unit := func(dir string, mod *Module) typedUnit {
+		if mod.Workspace {
+			return typedUnit{dir: dir, env: workspaceEnv()}
+		}
+		return typedUnit{dir: dir, env: []string{"GOWORK=off"}}
+	}
+
+	var units []typedUnit
+	rootMod := reg.moduleOf(reg.Path)
+	if rootMod != nil {
+		units = append(units, unit(reg.Path, rootMod))
+	}
+
+	for _, mod := range reg.Modules {
+		if mod != rootMod && !reg.containsPath(mod.Dir) {
+			units = append(units, unit(mod.Dir, mod))
+		}
+	}
+
+	// the directories walked for uses in the module of Path, or in a module
+	// containing it, are not reached by the units above
+	for _, dir := range reg.usageDirs {
+		if mod := reg.moduleOf(dir); mod != nil && (mod == rootMod || reg.containsPath(mod.Dir)) {
+			units = append(units, unit(dir, mod))
+		}
+	}
+
+	return units
+}
+
+// workspaceEnv returns the environment of the units of a go.work workspace,
+// where the go command rejects the -mod flag: it is dropped from GOFLAGS.
+func workspaceEnv() []string {
+	goflags := os.Getenv("GOFLAGS")
+	if goflags == "" {
+		return nil
+	}
+
+	var kept []string
+	for _, flag := range strings.Fields(goflags) {
+		if name, _, _ := strings.Cut(strings.TrimLeft(flag, "-"), "="); name != "mod" {
+			kept = append(kept, flag)
+		}
+	}
+	return []string{"GOFLAGS=" + strings.Join(kept, " ")}
+}
+
+// ParseFilesTyped loads the project packages through `go list`, type-checks
+// them and counts a use only when an identifier resolves to the object of an
+// exported declaration. UsageCount and TestUsage are keyed by Decl.Key in this
+// mode. Every build configuration is loaded in turn, adding up the uses, and
+// every module is loaded on its own, see typedUnits.
+func (reg *Registry) ParseFilesTyped() error {
+	if err := reg.loadModules(); err != nil {
+		return err
+	}
+
+	units := reg.typedUnits()
+	if len(units) == 0 {
+		return fmt.Errorf("no module found in %s", reg.Path)
+	}
+
+	for _, config := range reg.buildConfigs() {
+		loaded := make(map[string]bool)
+		for _, unit := range units {
+			if err := reg.parseTypedUnit(unit, config, loaded); err != nil {
+				return fmt.Errorf("%v: %v", config, err)
+			}
+		}
+
+		if len(loaded) == 0 {
+			return fmt.Errorf("%v: no packages found in %s", config, reg.Path)
+		}
+	}
+	return nil
+}
+
+// parseTypedUnit loads the packages of unit that are not in loaded yet, and
+// adds them to loaded
+func (reg *Registry) parseTypedUnit(unit typedUnit, config BuildConfig, loaded map[string]bool) error {
+	listed, err := goList(unit, config, "-deps", "-export", "-test", "./...")
+	if err != nil {
+		return err
+	}
+
+	// the interfaces of these are looked at even if nothing imports them
+	stdlib, err := goList(unit, config, append([]string{"-deps", "-export"}, stdlibInterfacePackages...)...)
+	if err != nil {
+		return err
+	}
+
+	exports := make(map[string]string)
+	for _, pkg := range stdlib {
+		if pkg.Export != "" {
+			exports[pkg.ImportPath] = pkg.Export
+		}
+	}
+
+	var targets []listedPackage
+
+	for _, pkg := range listed {
+		// test variants and generated test mains are rebuilt from source below
+		if pkg.ForTest != "" || strings.HasSuffix(pkg.ImportPath, ".test") {
+			continue
+		}
+
+		if pkg.Export != "" {
+			exports[pkg.ImportPath] = pkg.Export
+		}
+
+		if !pkg.DepOnly && !pkg.Standard && !reg.excluded(pkg.Dir) && reg.walked(pkg.Dir) && !loaded[pkg.ImportPath] {
+			loaded[pkg.ImportPath] = true
+			targets = append(targets, pkg)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	fset := reg.fileSet()
+	gc := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	})
+
+	checked := make(map[string]*types.Package)
+	sources := make(map[string][]*ast.File)
+
+	// go list -deps prints dependencies before the packages importing them,
+	// so every project import is already checked when it is needed.
+	for _, pkg := range targets {
+		files, err := parseListedFiles(fset, pkg.Dir, append(pkg.GoFiles, pkg.CgoFiles...))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			reg.collectDecls(file, pkg.ImportPath, fset)
+		}
+
+		imp := &typedImporter{checked: checked, importMap: pkg.ImportMap, gc: gc}
+		tpkg, info, err := reg.typeCheck(fset, pkg.ImportPath, files, imp)
+		if err != nil {
+			return err
+		}
+		reg.countTypedUses(fset, files, info)
+
+		checked[pkg.ImportPath] = tpkg
+		sources[pkg.ImportPath] = files
+	}
+
+	var projectPkgs, allPkgs []*types.Package
+	for _, pkg := range checked {
+		projectPkgs = append(projectPkgs, pkg)
+	}
+	allPkgs = append(allPkgs, projectPkgs...)
+	for path := range exports {
+		if _, ok := checked[path]; ok {
+			continue
+		}
+		if pkg, err := gc.Import(path); err == nil {
+			allPkgs = append(allPkgs, pkg)
+		}
+	}
+
+	reg.collectInterfaceMethods(projectPkgs, allPkgs)
+
+	for _, pkg := range targets {
+		testFiles, err := parseListedFiles(fset, pkg.Dir, pkg.TestGoFiles)
+		if err != nil {
+			return err
+		}
+
+		xtestFiles, err := parseListedFiles(fset, pkg.Dir, pkg.XTestGoFiles)
+		if err != nil {
+			return err
+		}
+
+		override := make(map[string]*types.Package)
+
+		if len(testFiles) > 0 {
+			// the package is checked again together with its in-package tests,
+			// but only the uses inside the test files are counted
+			files := append(append([]*ast.File{}, sources[pkg.ImportPath]...), testFiles...)
+			imp := &typedImporter{checked: checked, importMap: pkg.ImportMap, gc: gc}
+			tpkg, info, err := reg.typeCheck(fset, pkg.ImportPath, files, imp)
+			if err != nil {
+				return err
+			}
+			reg.countTypedUses(fset, testFiles, info)
+			override[pkg.ImportPath] = tpkg
+		}
+
+		if len(xtestFiles) > 0 {
+			imp := &typedImporter{checked: checked, override: override, importMap: pkg.ImportMap, gc: gc}
+			_, info, err := reg.typeCheck(fset, pkg.ImportPath+"_test", xtestFiles, imp)
+			if err != nil {
+				return err
+			}
+			reg.countTypedUses(fset, xtestFiles, info)
+		}
+	}
+
+	return nil
+}
+
+func parseListedFiles(fset *token.FileSet, dir string, names []string) ([]*ast.File, error) {
+	var files []*ast.File
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		file, err := parser.ParseFile(fset, path, nil, parser.AllErrors|parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing file %s: %v", path, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// typeCheck checks the files of a package. Type errors are tolerated, since
+// the analysis only needs the identifiers that could be resolved, and are
+// recorded in TypeErrors. An import that cannot be resolved is an error: the
+// uses of the package would be lost and its imports reported as unused.
+func (reg *Registry) typeCheck(fset *token.FileSet, path string, files []*ast.File, imp types.Importer) (*types.Package, *types.Info, error) {
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+
+	var importErr error
+	conf := types.Config{
+		Importer:    imp,
+		FakeImportC: true,
+		Error: func(err error) {
+			if typeErr, ok := err.(types.Error); ok && strings.HasPrefix(typeErr.Msg, "could not import ") {
+				if importErr == nil {
+					importErr = fmt.Errorf("error type-checking %s: %v", path, err)
+				}
+				return
+			}
+			reg.TypeErrors = append(reg.TypeErrors, err)
+		},
+	}
+
+	pkg, _ := conf.Check(path, fset, files, info)
+	return pkg, info, importErr
+}
+
+// countTypedUses counts every identifier in files that refers to a package
+// level object or a method under the key of the declaration of that object.
+// Fields are counted through the selector or composite literal naming them,
+// since a field object does not know the struct type declaring it.
+func (reg *Registry) countTypedUses(fset *token.FileSet, files []*ast.File, info *types.Info) {
+	for _, file := range files {
+		filename := fset.File(file.Pos()).Name()
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if sel, ok := info.Selections[n]; ok && sel.Kind() == types.FieldVal {
+					if key := fieldKey(sel.Recv(), sel.Index()); key != "" {
+						reg.addUse(key, filename)
+					}
+				}
+
+			case *ast.CompositeLit:
+				reg.countLiteralFields(n, info, filename)
+
+			case *ast.Ident:
+				if obj, ok := info.Uses[n]; ok {
+					if key := objectKey(obj); key != "" {
+						reg.addUse(key, filename)
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// countLiteralFields counts the fields set by a struct literal. Every field
+// is set by a literal without keys.
+func (reg *Registry) countLiteralFields(lit *ast.CompositeLit, info *types.Info, filename string) {
+	tv, ok := info.Types[lit]
+	if !ok {
+		return
+	}
+
+	named := namedOf(tv.Type)
+	if named == nil {
+		return
+	}
+
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				reg.addUse(memberDeclKey(named, key.Name), filename)
+			}
+		} else if i < st.NumFields() {
+			reg.addUse(memberDeclKey(named, st.Field(i).Name()), filename)
+		}
+	}
+}
+
+// fieldKey returns the Decl.Key of the field selected from recv through the
+// given path of field indices, or an empty string for unnamed struct types.
+func fieldKey(recv types.Type, index []int) string {
+	t := recv
+	for i, idx := range index {
+		named := namedOf(t)
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok || idx >= st.NumFields() {
+			return ""
+		}
+
+		field := st.Field(idx)
+		if i == len(index)-1 {
+			if named == nil {
+				return ""
+			}
+			return memberDeclKey(named, field.Name())
+		}
+		t = field.Type()
+	}
+	return ""
+}
+
+// memberDeclKey returns the Decl.Key of the method or field name of a named type
+func memberDeclKey(named *types.Named, name string) string {
+	obj := named.Obj()
+	if obj.Pkg() == nil {
+		return ""
+	}
+	return obj.Pkg().Path() + "." + obj.Name() + "." + name
+}
+
+// objectKey returns the Decl.Key of the declaration of obj, or an empty string
+// for objects that are not tracked (locals, fields, builtins, imports).
+func objectKey(obj types.Object) string {
+	pkg := obj.Pkg()
+	if pkg == nil {
+		return ""
+	}
+
+	switch o := obj.(type) {
+	case *types.Func:
+		sig, ok := o.Type().(*types.Signature)
+		if ok && sig.Recv() != nil {
+			named := namedOf(sig.Recv().Type())
+			if named == nil {
+				return ""
+			}
+			return memberDeclKey(named, o.Name())
+		}
+
+	case *types.Var:
+		if o.IsField() {
+			return ""
+		}
+
+	case *types.PkgName, *types.Label, *types.Builtin, *types.Nil:
+		return ""
+	}
+
+	if obj.Parent() != pkg.Scope() {
+		return ""
+	}
+
+	return pkg.Path() + "." + obj.Name()
+}
+
+// namedOf returns the generic origin of the named type t, looking through a
+// pointer, or nil when t is not a named type.
+func namedOf(t types.Type) *types.Named {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+
+	if named, ok := t.(*types.Named); ok {
+		return named.Origin()
+	}
+
+	return nil
+}
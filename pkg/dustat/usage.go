@@ -0,0 +1,170 @@
+package dustat
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// PackageUsage is the part of the analysis contributed by a single package:
+// its exported declarations and the uses it makes of exported declarations.
+// Drivers that check one package at a time, like go/analysis, pass it from a
+// package to the packages importing it and merge it back with AddPackageUsage.
+type PackageUsage struct {
+	Path             string              // Path is the import path of the package
+	Decls            []Decl              // Decls are the exported declarations not hidden by a suppression directive
+	Uses             map[string]int      // Uses counts the uses outside of tests, by Decl.Key
+	TestUses         map[string][]string // TestUses holds the _test.go files using each Decl.Key
+	InterfaceMethods map[string]string   // InterfaceMethods maps the keys of methods that satisfy an interface to its name
+}
+
+// AddTypedPackage adds the declarations and the uses of a package that was
+// type-checked by the caller, as ParseFilesTyped does for the packages it
+// loads. The declarations of _test.go files are not collected.
+//
+// Only the interfaces of the package, of the packages it imports, directly or
+// not, and of the stdlibInterfacePackages are known here. Exported methods are
+// matched against those, and the methods of the imported packages against the
+// interfaces of this one, like ParseFilesTyped does.
+func (reg *Registry) AddTypedPackage(fset *token.FileSet, pkg *types.Package, files []*ast.File, info *types.Info) {
+	for _, file := range files {
+		if !isTestFile(fset.File(file.Pos()).Name()) {
+			reg.collectDecls(file, pkg.Path(), fset)
+		}
+	}
+
+	reg.countTypedUses(fset, files, info)
+
+	imported := importedPackages(pkg)
+	ifaces := append(reg.stdlibInterfaces(pkg.Path(), imported), imported...)
+	reg.collectInterfaceMethods([]*types.Package{pkg}, append(ifaces, pkg))
+	reg.collectInterfaceMethods(imported, []*types.Package{pkg})
+}
+
+// stdlibInterfaces returns the stdlibInterfacePackages declaring an interface
+// with a method of the package at path, loaded from their export data unless
+// they are among the imported packages. A method can only implement one of
+// their interfaces with types of other packages when it imports them, so the
+// loaded packages do not need to be the same objects as the imported ones.
+// The packages that cannot be loaded are left out.
+func (reg *Registry) stdlibInterfaces(path string, imported []*types.Package) []*types.Package {
+	paths := make(map[string]bool)
+	for _, pkg := range imported {
+		paths[pkg.Path()] = true
+	}
+
+	needed := make(map[string]bool)
+	for _, decl := range reg.Declarations {
+		if decl.Kind != KindMethod || decl.Package != path {
+			continue
+		}
+		iface := stdlibInterfaceMethods[decl.Name].iface
+		if i := strings.LastIndex(iface, "."); i > 0 && !strings.Contains(iface, " ") && !paths[iface[:i]] {
+			needed[iface[:i]] = true
+		}
+	}
+	if len(needed) == 0 {
+		return nil
+	}
+
+	imp := importer.ForCompiler(token.NewFileSet(), "gc", nil)
+
+	var pkgs []*types.Package
+	for _, path := range stdlibInterfacePackages {
+		if !needed[path] {
+			continue
+		}
+		if pkg, err := imp.Import(path); err == nil {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// importedPackages returns the packages imported by pkg, directly or not
+func importedPackages(pkg *types.Package) []*types.Package {
+	seen := make(map[*types.Package]bool)
+	var imported []*types.Package
+
+	var visit func(pkg *types.Package)
+	visit = func(pkg *types.Package) {
+		for _, imp := range pkg.Imports() {
+			if !seen[imp] {
+				seen[imp] = true
+				imported = append(imported, imp)
+				visit(imp)
+			}
+		}
+	}
+	visit(pkg)
+
+	return imported
+}
+
+// PackageUsage returns what the package at path contributed to the registry.
+// The uses of every package are included, keep is called to only keep those of
+// the declarations that matter to the caller.
+func (reg *Registry) PackageUsage(path string, keep func(key string) bool) PackageUsage {
+	usage := PackageUsage{
+		Path:             path,
+		Decls:            []Decl{},
+		Uses:             make(map[string]int),
+		TestUses:         make(map[string][]string),
+		InterfaceMethods: make(map[string]string),
+	}
+
+	for _, decl := range reg.Declarations {
+		if decl.Package == path && reg.suppressed(decl) == nil {
+			usage.Decls = append(usage.Decls, decl)
+		}
+	}
+	sort.Slice(usage.Decls, func(i, j int) bool {
+		return usage.Decls[i].Key() < usage.Decls[j].Key()
+	})
+
+	for key, count := range reg.UsageCount {
+		if keep(key) {
+			usage.Uses[key] = count
+		}
+	}
+	for key, files := range reg.TestUsage {
+		if keep(key) {
+			for file := range files {
+				usage.TestUses[key] = append(usage.TestUses[key], file)
+			}
+			sort.Strings(usage.TestUses[key])
+		}
+	}
+	for key, iface := range reg.InterfaceMethods {
+		if keep(key) {
+			usage.InterfaceMethods[key] = iface
+		}
+	}
+
+	return usage
+}
+
+// AddPackageUsage merges what a package contributed to another registry.
+// Each package must only be added once.
+func (reg *Registry) AddPackageUsage(usage PackageUsage) {
+	for _, decl := range usage.Decls {
+		reg.Declarations[decl.Key()] = decl
+	}
+
+	for key, count := range usage.Uses {
+		reg.UsageCount[key] += count
+	}
+
+	for key, files := range usage.TestUses {
+		for _, file := range files {
+			reg.addUse(key, file)
+		}
+	}
+
+	for key, iface := range usage.InterfaceMethods {
+		reg.InterfaceMethods[key] = iface
+	}
+}
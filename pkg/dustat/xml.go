@@ -0,0 +1,143 @@
+package dustat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	Failure   junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// writeCheckstyle writes the result as a Checkstyle XML report, grouped by file
+func (reg *Registry) writeCheckstyle(w io.Writer) error {
+	report := checkstyleReport{Version: "4.3"}
+	for _, file := range groupByFile(reg.Result) {
+		entry := checkstyleFile{Name: file.name}
+		for _, decl := range file.decls {
+			entry.Errors = append(entry.Errors, checkstyleError{
+				Line:     decl.Pos.Line,
+				Column:   decl.Pos.Column,
+				Severity: decl.severity(),
+				Message:  fmt.Sprintf("%s (lines %d-%d)", decl.Message(), decl.Pos.Line, decl.End.Line),
+				Source:   "dustat." + string(decl.Category) + "." + string(decl.Kind),
+			})
+		}
+		report.Files = append(report.Files, entry)
+	}
+
+	return writeXML(w, report)
+}
+
+// writeJUnit writes the result as a JUnit XML report with a test suite per
+// file and a failed test case per declaration.
+func (reg *Registry) writeJUnit(w io.Writer) error {
+	report := junitTestSuites{Name: "dustat"}
+	for _, file := range groupByFile(reg.Result) {
+		suite := junitTestSuite{Name: file.name, Tests: len(file.decls), Failures: len(file.decls)}
+		for _, decl := range file.decls {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      decl.Key(),
+				Classname: decl.Package,
+				Failure: junitFailure{
+					Message: decl.Message(),
+					Type:    string(decl.Category),
+					Text: fmt.Sprintf("severity: %s\nkind: %s\nlocation: %s-%d:%d\nlines: %d\n",
+						decl.severity(), decl.Kind, decl.Pos, decl.End.Line, decl.End.Column, decl.LineCount),
+				},
+			})
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	return writeXML(w, report)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// fileDecls holds the declarations reported in a file
+type fileDecls struct {
+	name  string
+	decls []Decl
+}
+
+// groupByFile groups the declarations by file, sorted by path and position
+func groupByFile(decls []Decl) []fileDecls {
+	byFile := make(map[string][]Decl)
+	for _, decl := range decls {
+		byFile[decl.Pos.Filename] = append(byFile[decl.Pos.Filename], decl)
+	}
+
+	files := []fileDecls{}
+	for name, decls := range byFile {
+		sort.Slice(decls, func(i, j int) bool {
+			return decls[i].Pos.Offset < decls[j].Pos.Offset
+		})
+		files = append(files, fileDecls{name, decls})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	return files
+}